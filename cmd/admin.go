@@ -27,7 +27,7 @@ var adminCmd = &cobra.Command{
 		}
 
 		if service.AdminPort == 0 {
-			logger.Error("service is not a proxy")
+			logger.Error("service is not a proxy with an envoy admin interface")
 			os.Exit(1)
 		}
 
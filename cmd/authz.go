@@ -0,0 +1,101 @@
+package cmd
+
+import (
+	"os"
+
+	"github.com/andrewstucki/consul-services/pkg/server"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	authzPolicyFile string
+)
+
+// authzCmd represents the authz command
+var authzCmd = &cobra.Command{
+	Use:   "authz",
+	Short: "Manages the policy of the built-in ext_authz service",
+}
+
+// authzGetCmd represents the authz get command
+var authzGetCmd = &cobra.Command{
+	Use:   "get",
+	Short: "Prints the current ext_authz policy",
+	Args:  cobra.MatchAll(cobra.NoArgs),
+	Run: func(cmd *cobra.Command, args []string) {
+		logger := createLogger()
+
+		client := server.NewClient(socket)
+		policy, err := client.GetAuthzPolicy()
+		if err != nil {
+			logger.Error("unable to fetch policy", "err", err)
+			os.Exit(1)
+		}
+
+		printAuthzPolicy(policy)
+	},
+}
+
+// authzSetCmd represents the authz set command
+var authzSetCmd = &cobra.Command{
+	Use:   "set",
+	Short: "Replaces the ext_authz policy with one read from a YAML file",
+	Args:  cobra.MatchAll(cobra.NoArgs),
+	Run: func(cmd *cobra.Command, args []string) {
+		logger := createLogger()
+
+		data, err := os.ReadFile(authzPolicyFile)
+		if err != nil {
+			logger.Error("unable to read policy", "err", err)
+			os.Exit(1)
+		}
+
+		policy := server.AuthzPolicy{Default: server.AuthzAllow}
+		if err := yaml.Unmarshal(data, &policy); err != nil {
+			logger.Error("unable to parse policy", "err", err)
+			os.Exit(1)
+		}
+
+		client := server.NewClient(socket)
+		updated, err := client.SetAuthzPolicy(policy)
+		if err != nil {
+			logger.Error("unable to set policy", "err", err)
+			os.Exit(1)
+		}
+
+		printAuthzPolicy(updated)
+	},
+}
+
+// authzResetCmd represents the authz reset command
+var authzResetCmd = &cobra.Command{
+	Use:   "reset",
+	Short: "Resets the ext_authz policy to allow everything",
+	Args:  cobra.MatchAll(cobra.NoArgs),
+	Run: func(cmd *cobra.Command, args []string) {
+		logger := createLogger()
+
+		client := server.NewClient(socket)
+		if _, err := client.SetAuthzPolicy(server.AuthzPolicy{Default: server.AuthzAllow}); err != nil {
+			logger.Error("unable to reset policy", "err", err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(authzCmd)
+	authzCmd.AddCommand(authzGetCmd)
+	authzCmd.AddCommand(authzSetCmd)
+	authzCmd.AddCommand(authzResetCmd)
+
+	authzSetCmd.Flags().StringVarP(&authzPolicyFile, "file", "f", "", "Path to a YAML file containing the policy.")
+	authzSetCmd.MarkFlagRequired("file")
+}
+
+func printAuthzPolicy(policy *server.AuthzPolicy) {
+	encoder := yaml.NewEncoder(os.Stdout)
+	encoder.SetIndent(2)
+	encoder.Encode(policy)
+}
@@ -0,0 +1,70 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/andrewstucki/consul-services/pkg/server"
+	"github.com/spf13/cobra"
+)
+
+var (
+	caFormat string
+)
+
+// caCmd represents the ca command
+var caCmd = &cobra.Command{
+	Use:   "ca",
+	Short: "Interacts with the root CA used to issue certificates for config entries",
+}
+
+// caExportCmd represents the ca export command
+var caExportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Exports the root CA of the running environment",
+	Args:  cobra.MatchAll(cobra.NoArgs),
+	Run: func(cmd *cobra.Command, args []string) {
+		logger := createLogger()
+
+		client := server.NewClient(socket)
+
+		var ca string
+		var err error
+		switch caFormat {
+		case "pem":
+			ca, err = client.GetCertificateAuthority()
+		case "bundle":
+			ca, err = client.GetCertificateAuthorityBundle()
+		default:
+			err = fmt.Errorf("unsupported format %q", caFormat)
+		}
+		if err != nil {
+			logger.Error("unable to fetch certificate authority", "err", err)
+			os.Exit(1)
+		}
+
+		var w io.Writer = os.Stdout
+		if output != "" {
+			file, err := os.OpenFile(output, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+			if err != nil {
+				logger.Error("unable to open output file", "err", err)
+				os.Exit(1)
+			}
+			defer file.Close()
+			w = file
+		}
+
+		if _, err := fmt.Fprint(w, ca); err != nil {
+			logger.Error("unable to write certificate authority", "err", err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(caCmd)
+	caCmd.AddCommand(caExportCmd)
+
+	caExportCmd.Flags().StringVarP(&caFormat, "format", "f", "pem", "Format to export, either pem for the certificate or bundle for the certificate and private key.")
+}
@@ -0,0 +1,179 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/andrewstucki/consul-services/pkg/server"
+	"github.com/hashicorp/go-hclog"
+	"github.com/spf13/cobra"
+)
+
+var (
+	signalRandom bool
+	signalAfter  time.Duration
+	signalEvery  time.Duration
+	signalFor    time.Duration
+)
+
+func newChaosSignalCmd(action, short string) *cobra.Command {
+	return &cobra.Command{
+		Use:   action + " [kind] [name]",
+		Short: short,
+		Long: short + `.
+
+The kind "consul" targets the Consul agent of the datacenter given as the name. With --random
+the name is treated as a prefix and a random matching instance is chosen each time, i.e.
+"http-dc1-1" to choose between the duplicates of http-1 in dc1.`,
+		Args: cobra.MatchAll(cobra.ExactArgs(2)),
+		Run: func(cmd *cobra.Command, args []string) {
+			kind, name := args[0], args[1]
+			logger := createLogger()
+
+			if signalFor > 0 && action != server.ActionPause {
+				logger.Error("--for can only be used when pausing")
+				os.Exit(1)
+			}
+
+			ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+			defer cancel()
+
+			client := server.NewClient(socket)
+			signaler := &instanceSignaler{
+				client: client,
+				logger: logger,
+				kind:   kind,
+				name:   name,
+				action: action,
+			}
+
+			if !sleepContext(ctx, signalAfter) {
+				return
+			}
+			for {
+				if err := signaler.signal(ctx); err != nil {
+					logger.Error("unable to signal instance", "action", action, "err", err)
+					os.Exit(1)
+				}
+				if signalEvery <= 0 || !sleepContext(ctx, signalEvery) {
+					return
+				}
+			}
+		},
+	}
+}
+
+var (
+	// chaosKillCmd represents the chaos kill command
+	chaosKillCmd = newChaosSignalCmd(server.ActionKill, "Kills an instance without letting it clean up")
+	// chaosPauseCmd represents the chaos pause command
+	chaosPauseCmd = newChaosSignalCmd(server.ActionPause, "Freezes an instance until it's resumed")
+	// chaosResumeCmd represents the chaos resume command
+	chaosResumeCmd = newChaosSignalCmd(server.ActionResume, "Resumes a paused instance")
+)
+
+func init() {
+	for _, cmd := range []*cobra.Command{chaosKillCmd, chaosPauseCmd, chaosResumeCmd} {
+		chaosCmd.AddCommand(cmd)
+
+		cmd.Flags().BoolVar(&signalRandom, "random", false, "Treat the name as a prefix and choose a random matching instance.")
+		cmd.Flags().DurationVar(&signalAfter, "after", 0, "Wait for the given duration before acting.")
+		cmd.Flags().DurationVar(&signalEvery, "every", 0, "Keep acting on the given interval until interrupted, best combined with --random.")
+	}
+	chaosPauseCmd.Flags().DurationVar(&signalFor, "for", 0, "Resume the instance after the given duration.")
+}
+
+// instanceSignaler kills, pauses or resumes instances through the control server
+type instanceSignaler struct {
+	client *server.Client
+	logger hclog.Logger
+	kind   string
+	name   string
+	action string
+}
+
+func (s *instanceSignaler) signal(ctx context.Context) error {
+	name, err := s.target()
+	if err != nil {
+		return err
+	}
+
+	if err := s.send(name, s.action); err != nil {
+		return err
+	}
+	s.logger.Info("signaled instance", "action", s.action, "kind", s.kind, "name", name)
+
+	if signalFor <= 0 {
+		return nil
+	}
+
+	// resume even if we're interrupted so we don't leave the instance frozen
+	sleepContext(ctx, signalFor)
+	if err := s.send(name, server.ActionResume); err != nil {
+		return err
+	}
+	s.logger.Info("signaled instance", "action", server.ActionResume, "kind", s.kind, "name", name)
+	return nil
+}
+
+func (s *instanceSignaler) send(name, action string) error {
+	if s.kind == "consul" {
+		return s.client.SignalConsul(name, action)
+	}
+	return s.client.Signal(s.kind, name, action)
+}
+
+// target returns the name of the instance to signal
+func (s *instanceSignaler) target() (string, error) {
+	if !signalRandom {
+		return s.name, nil
+	}
+
+	candidates := []string{}
+	if s.kind == "consul" {
+		consuls, err := s.client.ListConsuls()
+		if err != nil {
+			return "", err
+		}
+		for _, consul := range consuls {
+			if strings.HasPrefix(consul.Datacenter, s.name) {
+				candidates = append(candidates, consul.Datacenter)
+			}
+		}
+	} else {
+		services, err := s.client.List(s.kind)
+		if err != nil {
+			return "", err
+		}
+		for _, service := range services {
+			if strings.HasPrefix(service.Name, s.name) {
+				candidates = append(candidates, service.Name)
+			}
+		}
+	}
+
+	if len(candidates) == 0 {
+		return "", fmt.Errorf("no %s instances found matching %q", s.kind, s.name)
+	}
+	return candidates[rand.Intn(len(candidates))], nil
+}
+
+// sleepContext sleeps for the given duration, returning false if the context
+// was canceled first
+func sleepContext(ctx context.Context, duration time.Duration) bool {
+	if duration <= 0 {
+		return true
+	}
+
+	select {
+	case <-time.After(duration):
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
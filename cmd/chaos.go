@@ -0,0 +1,97 @@
+package cmd
+
+import (
+	"os"
+	"time"
+
+	"github.com/andrewstucki/consul-services/pkg/server"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	chaosLatency   time.Duration
+	chaosJitter    time.Duration
+	chaosBandwidth int
+	chaosDropRate  float64
+	chaosSlowClose time.Duration
+	chaosBlackhole bool
+	chaosReset     bool
+)
+
+// chaosCmd represents the chaos command
+var chaosCmd = &cobra.Command{
+	Use:   "chaos [name]",
+	Short: "Injects network faults between a service and its sidecar, run with --chaos to enable",
+	Long: `Injects network faults between a service and its sidecar, run with --chaos to enable.
+
+Only the given settings are changed, the rest are left as they are. With no settings the
+current ones are printed.`,
+	Args: cobra.MatchAll(cobra.ExactArgs(1)),
+	Run: func(cmd *cobra.Command, args []string) {
+		name := args[0]
+		logger := createLogger()
+
+		client := server.NewClient(socket)
+		settings, err := client.GetChaos(kind, name)
+		if err != nil {
+			logger.Error("unable to fetch chaos settings", "err", err)
+			os.Exit(1)
+		}
+
+		flags := cmd.Flags()
+		updated := chaosReset
+		if chaosReset {
+			settings = &server.ChaosSettings{}
+		}
+		for flag, apply := range map[string]func(){
+			"latency":    func() { settings.Latency = chaosLatency },
+			"jitter":     func() { settings.Jitter = chaosJitter },
+			"bandwidth":  func() { settings.Bandwidth = chaosBandwidth },
+			"drop":       func() { settings.DropRate = chaosDropRate },
+			"slow-close": func() { settings.SlowClose = chaosSlowClose },
+			"blackhole":  func() { settings.Blackhole = chaosBlackhole },
+		} {
+			if flags.Changed(flag) {
+				apply()
+				updated = true
+			}
+		}
+
+		if updated {
+			settings, err = client.SetChaos(kind, name, *settings)
+			if err != nil {
+				logger.Error("unable to update chaos settings", "err", err)
+				os.Exit(1)
+			}
+		}
+
+		printChaosSettings(settings)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(chaosCmd)
+
+	chaosCmd.Flags().StringVarP(&kind, "kind", "k", defaultKind, "Kind of service to lookup.")
+	chaosCmd.Flags().DurationVar(&chaosLatency, "latency", 0, "Latency to add to each chunk of data forwarded in either direction.")
+	chaosCmd.Flags().DurationVar(&chaosJitter, "jitter", 0, "Random amount of additional latency up to the given duration.")
+	chaosCmd.Flags().IntVar(&chaosBandwidth, "bandwidth", 0, "Limit each direction of a connection to the given KB/s, 0 is unlimited.")
+	chaosCmd.Flags().Float64Var(&chaosDropRate, "drop", 0, "Probability, between 0 and 1, that a new connection is reset.")
+	chaosCmd.Flags().DurationVar(&chaosSlowClose, "slow-close", 0, "Delay closing the sidecar's connection once the service has closed its side.")
+	chaosCmd.Flags().BoolVar(&chaosBlackhole, "blackhole", false, "Accept connections and discard everything without ever responding.")
+	chaosCmd.Flags().BoolVar(&chaosReset, "reset", false, "Remove all faults before applying any other settings.")
+}
+
+func printChaosSettings(settings *server.ChaosSettings) {
+	encoder := yaml.NewEncoder(os.Stdout)
+	encoder.SetIndent(2)
+	encoder.Encode(map[string]interface{}{
+		"latency":   settings.Latency.String(),
+		"jitter":    settings.Jitter.String(),
+		"bandwidth": settings.Bandwidth,
+		"drop":      settings.DropRate,
+		"slowClose": settings.SlowClose.String(),
+		"blackhole": settings.Blackhole,
+	})
+}
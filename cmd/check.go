@@ -4,14 +4,43 @@ Copyright © 2023 NAME HERE <EMAIL ADDRESS>
 package cmd
 
 import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"os"
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/andrewstucki/consul-services/pkg/echo"
 	"github.com/andrewstucki/consul-services/pkg/server"
 	"github.com/spf13/cobra"
+	"golang.org/x/net/http2"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// checkFailedCode is the exit code used when a check succeeds in
+	// connecting but the response doesn't match the expectations
+	checkFailedCode = 2
+	// nativeKind is the kind of connect-native services
+	nativeKind = "native"
+)
+
+var (
+	expectBody     string
+	expectStatus   int
+	expectInstance string
+	checkTimeout   time.Duration
 )
 
 // checkCmd represents the check command
@@ -24,54 +53,332 @@ var checkCmd = &cobra.Command{
 		upstream := args[1]
 		logger := createLogger()
 
-		// normalize the name to add the -proxy so that we know we're querying
-		// the upstream port from the connect proxy rather than from the service
-		// itself
-		if !strings.HasSuffix(name, "-proxy") {
-			name += "-proxy"
-		}
-
 		client := server.NewClient(socket)
-		service, err := client.Get(kind, name)
-		if err != nil {
-			logger.Error("unable to fetch service", "err", err)
-			os.Exit(1)
-		}
 
-		port, ok := service.NamedPorts[upstream]
-		if !ok {
-			logger.Error("service does not have upstream defined", "upstream", upstream)
-			os.Exit(1)
+		var result *checkResult
+		var err error
+		if kind == nativeKind {
+			result, err = checkNative(client, name, upstream)
+		} else {
+			result, err = checkProxy(client, name, upstream)
 		}
-
-		response, err := checkConnectivity(port)
 		if err != nil {
 			logger.Error("error connecting to upstream", "err", err)
 			os.Exit(1)
 		}
 
-		logger.Info("response received", "response", response)
+		expectations := checkExpectations{
+			body:     expectBody,
+			status:   expectStatus,
+			instance: expectInstance,
+		}
+		if mismatches := expectations.verify(result); len(mismatches) > 0 {
+			for _, mismatch := range mismatches {
+				logger.Error("check failed", "field", mismatch.Field, "expected", mismatch.Expected, "actual", mismatch.Actual)
+			}
+			os.Exit(checkFailedCode)
+		}
+
+		if printEcho(os.Stdout, []byte(result.Body)) {
+			return
+		}
+		logger.Info("response received", "response", result.Body)
 	},
 }
 
 func init() {
 	rootCmd.AddCommand(checkCmd)
 
-	checkCmd.Flags().StringVarP(&kind, "kind", "k", defaultKind, "Kind of service to lookup.")
+	checkCmd.Flags().StringVarP(&kind, "kind", "k", defaultKind, "Kind of service to lookup, connect-native services dial their upstreams themselves.")
+	checkCmd.Flags().StringVar(&expectBody, "expect-body", "", "Fail unless the response body contains the given string.")
+	checkCmd.Flags().IntVar(&expectStatus, "expect-status", 0, "Fail unless the response has the given HTTP status code, gRPC status codes are mapped to their HTTP equivalent.")
+	checkCmd.Flags().StringVar(&expectInstance, "expect-instance", "", "Fail unless the response came from the service instance with the given id.")
+	checkCmd.Flags().DurationVar(&checkTimeout, "timeout", 5*time.Second, "Timeout for connecting to and reading from the upstream.")
 }
 
-func checkConnectivity(port int) (string, error) {
-	url := fmt.Sprintf("http://localhost:%d", port)
-	response, err := http.Get(url)
+// checkProxy checks the upstream through the listener of the sidecar proxy of the service
+func checkProxy(client *server.Client, name, upstream string) (*checkResult, error) {
+	// normalize the name to add the -proxy so that we know we're querying
+	// the upstream port from the connect proxy rather than from the service
+	// itself
+	if !strings.HasSuffix(name, "-proxy") {
+		name += "-proxy"
+	}
+
+	service, err := client.Get(kind, name)
 	if err != nil {
-		return "", err
+		return nil, fmt.Errorf("unable to fetch service: %w", err)
+	}
+
+	port, ok := service.NamedPorts[upstream]
+	if !ok {
+		return nil, fmt.Errorf("service does not have upstream %q defined", upstream)
+	}
+
+	return checkConnectivity(upstreamProtocol(service, upstream), fmt.Sprintf("localhost:%d", port), nil, checkTimeout)
+}
+
+// checkNative checks the upstream the way a connect-native service would, resolving
+// it through Consul and dialing it directly with the certificate of the service
+func checkNative(client *server.Client, name, upstream string) (*checkResult, error) {
+	service, err := client.Get(kind, name)
+	if err != nil {
+		return nil, fmt.Errorf("unable to fetch service: %w", err)
+	}
+	if _, ok := service.Upstreams[upstream]; !ok {
+		return nil, fmt.Errorf("service does not have upstream %q defined", upstream)
+	}
+
+	consul, err := client.GetConsul(service.Datacenter)
+	if err != nil {
+		return nil, fmt.Errorf("unable to fetch consul instance: %w", err)
+	}
+	port := consul.NamedPorts["http"]
+	if port == 0 {
+		return nil, errors.New("consul HTTP port not registered")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), checkTimeout)
+	defer cancel()
+
+	source, err := nativeSource(ctx, fmt.Sprintf("127.0.0.1:%d", port), service.ConsulService)
+	if err != nil {
+		return nil, fmt.Errorf("unable to fetch connect-native certificates: %w", err)
+	}
+	address, err := source.Resolve(ctx, upstream, service.Datacenter)
+	if err != nil {
+		return nil, err
+	}
+
+	return checkConnectivity(upstreamProtocol(service, upstream), address, source.ClientTLSConfig(upstream), checkTimeout)
+}
+
+// upstreamProtocol returns the protocol of the given upstream, defaulting to
+// http for anything we don't know about
+func upstreamProtocol(service *server.Service, upstream string) string {
+	if protocol := service.Upstreams[upstream]; protocol != "" {
+		return protocol
+	}
+	return "http"
+}
+
+// checkResult is the response received when checking connectivity
+type checkResult struct {
+	// Status is the HTTP status code of the response, gRPC status codes are
+	// mapped to their HTTP equivalent, 0 for TCP upstreams
+	Status int
+	// Body is the body read from the upstream
+	Body string
+	// Instance is the id of the service instance that responded
+	Instance string
+}
+
+// checkConnectivity checks the upstream at the given address, using mTLS when
+// a TLS configuration is given
+func checkConnectivity(protocol, address string, tlsConfig *tls.Config, timeout time.Duration) (*checkResult, error) {
+	switch protocol {
+	case "tcp":
+		return checkTCPConnectivity(address, tlsConfig, timeout)
+	case "grpc":
+		return checkGRPCConnectivity(address, tlsConfig, timeout)
+	case "http2":
+		return checkHTTP2Connectivity(address, tlsConfig, timeout)
+	default:
+		return checkHTTPConnectivity(address, tlsConfig, timeout)
+	}
+}
+
+func checkTCPConnectivity(address string, tlsConfig *tls.Config, timeout time.Duration) (*checkResult, error) {
+	conn, err := dialTimeout(address, tlsConfig, timeout)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if err := conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+		return nil, err
+	}
+
+	data, err := io.ReadAll(conn)
+	if err != nil {
+		return nil, err
+	}
+
+	body := string(data)
+	return &checkResult{
+		Body:     body,
+		Instance: strings.TrimSpace(body),
+	}, nil
+}
+
+// dialTimeout dials the address, over TLS when a configuration is given
+func dialTimeout(address string, tlsConfig *tls.Config, timeout time.Duration) (net.Conn, error) {
+	if tlsConfig == nil {
+		return net.DialTimeout("tcp", address, timeout)
+	}
+	return tls.DialWithDialer(&net.Dialer{Timeout: timeout}, "tcp", address, tlsConfig)
+}
+
+func checkHTTPConnectivity(address string, tlsConfig *tls.Config, timeout time.Duration) (*checkResult, error) {
+	return checkHTTPClientConnectivity(&http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			TLSClientConfig: tlsConfig,
+		},
+	}, address, tlsConfig != nil)
+}
+
+// checkHTTP2Connectivity checks an HTTP/2 upstream, speaking HTTP/2 in cleartext
+// with prior knowledge as the upstream listener expects, or negotiating it over mTLS
+func checkHTTP2Connectivity(address string, tlsConfig *tls.Config, timeout time.Duration) (*checkResult, error) {
+	return checkHTTPClientConnectivity(&http.Client{
+		Timeout: timeout,
+		Transport: &http2.Transport{
+			AllowHTTP: true,
+			DialTLS: func(network, address string, _ *tls.Config) (net.Conn, error) {
+				return dialTimeout(address, withNextProtos(tlsConfig), timeout)
+			},
+		},
+	}, address, tlsConfig != nil)
+}
+
+// withNextProtos negotiates HTTP/2 over the TLS connection
+func withNextProtos(tlsConfig *tls.Config) *tls.Config {
+	if tlsConfig == nil {
+		return nil
+	}
+	tlsConfig = tlsConfig.Clone()
+	tlsConfig.NextProtos = []string{http2.NextProtoTLS}
+	return tlsConfig
+}
+
+func checkHTTPClientConnectivity(client *http.Client, address string, secure bool) (*checkResult, error) {
+	scheme := "http"
+	if secure {
+		scheme = "https"
+	}
+
+	url := fmt.Sprintf("%s://%s", scheme, address)
+	request, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Accept", "application/json")
+
+	response, err := client.Do(request)
+	if err != nil {
+		return nil, err
 	}
 	defer response.Body.Close()
 
 	data, err := io.ReadAll(response.Body)
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+
+	return &checkResult{
+		Status:   response.StatusCode,
+		Body:     string(data),
+		Instance: responseInstance(data),
+	}, nil
+}
+
+// grpcStatuses maps gRPC status codes to their HTTP equivalents so that gRPC
+// upstreams can be checked the same way as HTTP ones
+var grpcStatuses = map[codes.Code]int{
+	codes.OK:               http.StatusOK,
+	codes.InvalidArgument:  http.StatusBadRequest,
+	codes.Unauthenticated:  http.StatusUnauthorized,
+	codes.PermissionDenied: http.StatusForbidden,
+	codes.NotFound:         http.StatusNotFound,
+	codes.Unimplemented:    http.StatusNotImplemented,
+	codes.Unavailable:      http.StatusServiceUnavailable,
+	codes.DeadlineExceeded: http.StatusGatewayTimeout,
+}
+
+// checkGRPCConnectivity checks a gRPC upstream by calling the echo method of
+// our test services
+func checkGRPCConnectivity(address string, tlsConfig *tls.Config, timeout time.Duration) (*checkResult, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	transportCredentials := insecure.NewCredentials()
+	if tlsConfig != nil {
+		transportCredentials = credentials.NewTLS(tlsConfig)
+	}
+
+	// block so that failing to reach the upstream is an error rather than a status
+	conn, err := grpc.DialContext(ctx, address, grpc.WithTransportCredentials(transportCredentials), grpc.WithBlock())
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	data, err := echo.CallGRPC(ctx, conn)
+	if err != nil {
+		code := status.Code(err)
+		httpStatus, ok := grpcStatuses[code]
+		if !ok {
+			httpStatus = http.StatusInternalServerError
+		}
+		return &checkResult{
+			Status: httpStatus,
+			Body:   fmt.Sprintf("%s: %s", code, status.Convert(err).Message()),
+		}, nil
 	}
 
-	return string(data), nil
+	return &checkResult{
+		Status:   http.StatusOK,
+		Body:     string(data),
+		Instance: responseInstance(data),
+	}, nil
+}
+
+// responseInstance returns the id of the instance that sent the response,
+// our test services either echo it back in JSON or return it as the body
+func responseInstance(body []byte) string {
+	response := &echo.Response{}
+	if err := json.Unmarshal(body, response); err == nil && response.ID != "" {
+		return response.ID
+	}
+	return strings.TrimSpace(string(body))
+}
+
+type checkExpectations struct {
+	body     string
+	status   int
+	instance string
+}
+
+// checkMismatch is an expectation that a check result failed to meet
+type checkMismatch struct {
+	Field    string
+	Expected string
+	Actual   string
+}
+
+func (e checkExpectations) verify(result *checkResult) []checkMismatch {
+	mismatches := []checkMismatch{}
+	if e.body != "" && !strings.Contains(result.Body, e.body) {
+		mismatches = append(mismatches, checkMismatch{
+			Field:    "body",
+			Expected: e.body,
+			Actual:   result.Body,
+		})
+	}
+	if e.status != 0 && e.status != result.Status {
+		mismatches = append(mismatches, checkMismatch{
+			Field:    "status",
+			Expected: strconv.Itoa(e.status),
+			Actual:   strconv.Itoa(result.Status),
+		})
+	}
+	if e.instance != "" && e.instance != result.Instance {
+		mismatches = append(mismatches, checkMismatch{
+			Field:    "instance",
+			Expected: e.instance,
+			Actual:   result.Instance,
+		})
+	}
+	return mismatches
 }
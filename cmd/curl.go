@@ -0,0 +1,280 @@
+package cmd
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/andrewstucki/consul-services/pkg/server"
+	"github.com/spf13/cobra"
+)
+
+var (
+	curlMethod   string
+	curlHeaders  []string
+	curlRaw      bool
+	curlCACert   string
+	curlInsecure bool
+)
+
+// curlCmd represents the curl command
+var curlCmd = &cobra.Command{
+	Use:   "curl [name]/[upstream|listener] [host][/path]",
+	Short: "Makes an HTTP request to a service's upstream or a gateway listener and prints the response",
+	Args:  cobra.MatchAll(cobra.RangeArgs(1, 2)),
+	Run: func(cmd *cobra.Command, args []string) {
+		logger := createLogger()
+
+		name, portName, found := strings.Cut(args[0], "/")
+		if !found || name == "" || portName == "" {
+			logger.Error("target must be of the form [name]/[upstream|listener]", "target", args[0])
+			os.Exit(1)
+		}
+
+		host, path := "", "/"
+		if len(args) > 1 {
+			host, path = splitHostPath(args[1])
+		}
+
+		client := server.NewClient(socket)
+		target, err := resolveCurlTarget(client, name, portName)
+		if err != nil {
+			logger.Error("unable to resolve target", "err", err)
+			os.Exit(1)
+		}
+		if host == "" {
+			host = target.host
+		}
+
+		scheme := "http"
+		transport := &http.Transport{}
+		if target.tls {
+			scheme = "https"
+			config, err := curlTLSConfig(client, host)
+			if err != nil {
+				logger.Error("unable to configure TLS", "err", err)
+				os.Exit(1)
+			}
+			transport.TLSClientConfig = config
+		}
+
+		request, err := http.NewRequest(curlMethod, fmt.Sprintf("%s://localhost:%d%s", scheme, target.port, path), nil)
+		if err != nil {
+			logger.Error("unable to create request", "err", err)
+			os.Exit(1)
+		}
+		if host != "" {
+			request.Host = host
+		}
+		if err := setHeaders(request, curlHeaders); err != nil {
+			logger.Error("invalid header", "err", err)
+			os.Exit(1)
+		}
+		if !curlRaw && request.Header.Get("Accept") == "" {
+			request.Header.Set("Accept", "application/json")
+		}
+
+		timings := &requestTimings{}
+		request = request.WithContext(httptrace.WithClientTrace(request.Context(), timings.trace()))
+
+		httpClient := &http.Client{Transport: transport}
+		timings.start = time.Now()
+		response, err := httpClient.Do(request)
+		if err != nil {
+			logger.Error("error making request", "err", err)
+			os.Exit(1)
+		}
+		defer response.Body.Close()
+
+		body, err := io.ReadAll(response.Body)
+		if err != nil {
+			logger.Error("error reading response", "err", err)
+			os.Exit(1)
+		}
+		total := time.Since(timings.start)
+
+		logger.Info("response received",
+			"status", response.StatusCode,
+			"connect", timings.connect,
+			"tls", timings.tls,
+			"first-byte", timings.firstByte,
+			"total", total,
+		)
+		if curlRaw || !printEcho(os.Stdout, body) {
+			os.Stdout.Write(body)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(curlCmd)
+
+	curlCmd.Flags().StringVarP(&curlMethod, "request", "X", http.MethodGet, "HTTP method to use.")
+	curlCmd.Flags().StringArrayVarP(&curlHeaders, "header", "H", nil, "Extra headers to send, i.e. 'x-test: value'.")
+	curlCmd.Flags().BoolVar(&curlRaw, "raw", false, "Don't ask the service to echo the request, just print the response body.")
+	curlCmd.Flags().StringVar(&curlCACert, "cacert", "", "Path to an additional CA certificate to trust for TLS listeners.")
+	curlCmd.Flags().BoolVar(&curlInsecure, "insecure", false, "Skip verification of TLS certificates.")
+}
+
+// curlTarget is the resolved location to send a request to
+type curlTarget struct {
+	port int
+	host string
+	tls  bool
+}
+
+// resolveCurlTarget resolves name/port to either an upstream of a service's
+// sidecar or to a gateway listener
+func resolveCurlTarget(client *server.Client, name, portName string) (*curlTarget, error) {
+	proxy, err := client.Get("connect-proxy", strings.TrimSuffix(name, "-proxy")+"-proxy")
+	if err == nil {
+		port, ok := proxy.NamedPorts[portName]
+		if !ok {
+			return nil, fmt.Errorf("service does not have upstream %q defined", portName)
+		}
+		return &curlTarget{port: port}, nil
+	}
+
+	gateway, err := findGateway(client, name)
+	if err != nil {
+		return nil, fmt.Errorf("no service or gateway named %q found", name)
+	}
+
+	for _, listener := range gateway.Listeners {
+		if listener.Name == portName || strconv.Itoa(listener.Port) == portName {
+			return &curlTarget{
+				port: listener.Port,
+				host: defaultHostname(listener.Hostnames),
+				tls:  listener.TLS,
+			}, nil
+		}
+	}
+
+	// fall back to the ports that were allocated for the gateway
+	if port, ok := gateway.NamedPorts[portName]; ok {
+		for _, listener := range gateway.Listeners {
+			if listener.Port == port {
+				return &curlTarget{
+					port: port,
+					host: defaultHostname(listener.Hostnames),
+					tls:  listener.TLS,
+				}, nil
+			}
+		}
+		return &curlTarget{port: port}, nil
+	}
+
+	return nil, fmt.Errorf("gateway %q does not have listener %q", name, portName)
+}
+
+// defaultHostname returns the first hostname that we can send a request to
+// without needing to make one up
+func defaultHostname(hostnames []string) string {
+	for _, hostname := range hostnames {
+		if !strings.Contains(hostname, "*") {
+			return hostname
+		}
+	}
+	return ""
+}
+
+// curlTLSConfig trusts the CA used to issue certificates for config entries, listeners
+// without a hostname to send are verified as localhost, which is what we dial
+func curlTLSConfig(client *server.Client, host string) (*tls.Config, error) {
+	if host == "" {
+		host = "localhost"
+	}
+
+	config := &tls.Config{
+		ServerName:         host,
+		InsecureSkipVerify: curlInsecure,
+	}
+	if curlInsecure {
+		return config, nil
+	}
+
+	pool := x509.NewCertPool()
+	ca, err := client.GetCertificateAuthority()
+	if err != nil {
+		return nil, err
+	}
+	pool.AppendCertsFromPEM([]byte(ca))
+
+	if curlCACert != "" {
+		data, err := os.ReadFile(curlCACert)
+		if err != nil {
+			return nil, err
+		}
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, errors.New("no certificates found in --cacert")
+		}
+	}
+
+	config.RootCAs = pool
+	return config, nil
+}
+
+// requestTimings records how long each phase of a request took
+type requestTimings struct {
+	start        time.Time
+	connectStart time.Time
+	tlsStart     time.Time
+
+	connect   time.Duration
+	tls       time.Duration
+	firstByte time.Duration
+}
+
+func (r *requestTimings) trace() *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		ConnectStart: func(_, _ string) {
+			r.connectStart = time.Now()
+		},
+		ConnectDone: func(_, _ string, _ error) {
+			r.connect = time.Since(r.connectStart)
+		},
+		TLSHandshakeStart: func() {
+			r.tlsStart = time.Now()
+		},
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			r.tls = time.Since(r.tlsStart)
+		},
+		GotFirstResponseByte: func() {
+			r.firstByte = time.Since(r.start)
+		},
+	}
+}
+
+// splitHostPath splits a target of the form host/path, /path or host
+func splitHostPath(target string) (string, string) {
+	index := strings.Index(target, "/")
+	if index == -1 {
+		return target, "/"
+	}
+	return target[:index], target[index:]
+}
+
+func setHeaders(request *http.Request, headers []string) error {
+	for _, header := range headers {
+		name, value, found := strings.Cut(header, ":")
+		if !found {
+			return errors.New("headers must be of the form 'name: value'")
+		}
+		name = strings.TrimSpace(name)
+		value = strings.TrimSpace(value)
+		if strings.EqualFold(name, "host") {
+			request.Host = value
+			continue
+		}
+		request.Header.Add(name, value)
+	}
+	return nil
+}
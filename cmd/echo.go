@@ -0,0 +1,33 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+
+	"github.com/andrewstucki/consul-services/pkg/echo"
+)
+
+// printEcho pretty prints a response body if it is an echo response or a
+// tree of calls from one of our test services, returning false if it isn't.
+func printEcho(w io.Writer, body []byte) bool {
+	if response := (&echo.Response{}); decodeStrict(body, response) && response.ID != "" {
+		return printIndented(w, response)
+	}
+	if call := (&echo.Call{}); decodeStrict(body, call) && len(call.Calls) > 0 {
+		return printIndented(w, call)
+	}
+	return false
+}
+
+func decodeStrict(body []byte, v interface{}) bool {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.DisallowUnknownFields()
+	return decoder.Decode(v) == nil
+}
+
+func printIndented(w io.Writer, v interface{}) bool {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v) == nil
+}
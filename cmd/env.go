@@ -0,0 +1,171 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/andrewstucki/consul-services/pkg/server"
+	"github.com/spf13/cobra"
+)
+
+var (
+	envFormat string
+
+	envFormats = map[string]func(w io.Writer, variables []envVariable) error{
+		"sh":     writeShellEnv,
+		"fish":   writeFishEnv,
+		"dotenv": writeDotEnv,
+		"json":   writeJSONEnv,
+	}
+
+	invalidEnvCharacters = regexp.MustCompile(`[^A-Z0-9]+`)
+)
+
+// envCmd represents the env command
+var envCmd = &cobra.Command{
+	Use:   "env",
+	Short: "Prints environment variables for the addresses and ports of the running environment",
+	Args:  cobra.MatchAll(cobra.NoArgs),
+	Run: func(cmd *cobra.Command, args []string) {
+		logger := createLogger()
+
+		write, ok := envFormats[envFormat]
+		if !ok {
+			logger.Error("unsupported format", "format", envFormat)
+			os.Exit(1)
+		}
+
+		client := server.NewClient(socket)
+		consuls, err := client.ListConsuls()
+		if err != nil {
+			logger.Error("unable to fetch consul instances", "err", err)
+			os.Exit(1)
+		}
+		services, err := client.List()
+		if err != nil {
+			logger.Error("unable to fetch services", "err", err)
+			os.Exit(1)
+		}
+
+		if err := write(os.Stdout, environmentFor(consuls, services)); err != nil {
+			logger.Error("unable to write environment", "err", err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(envCmd)
+
+	envCmd.Flags().StringVarP(&envFormat, "format", "f", "sh", "Output format, one of sh, fish, dotenv or json.")
+}
+
+type envVariable struct {
+	Name  string
+	Value string
+}
+
+// environmentFor builds the variables for every consul instance and service
+func environmentFor(consuls []server.Consul, services []server.Service) []envVariable {
+	variables := map[string]string{}
+	set := func(value string, parts ...string) {
+		variables[envName(parts...)] = value
+	}
+
+	for _, consul := range consuls {
+		if port := consul.NamedPorts["http"]; port != 0 {
+			set(fmt.Sprintf("127.0.0.1:%d", port), "CONSUL_HTTP_ADDR", consul.Datacenter)
+			if consul.Primary {
+				// default the consul CLI to the primary datacenter
+				set(fmt.Sprintf("127.0.0.1:%d", port), "CONSUL_HTTP_ADDR")
+			}
+		}
+		if port := consul.NamedPorts["grpc"]; port != 0 {
+			set(fmt.Sprintf("127.0.0.1:%d", port), "CONSUL_GRPC_ADDR", consul.Datacenter)
+		}
+	}
+
+	for _, service := range services {
+		name := strings.TrimSuffix(service.Name, "-proxy")
+
+		if service.AdminPort != 0 {
+			set(strconv.Itoa(service.AdminPort), "ADMIN", name, "PORT")
+		}
+
+		switch {
+		case gatewayKinds[service.Kind]:
+			named := map[int]bool{}
+			for portName, port := range service.NamedPorts {
+				named[port] = true
+				set(strconv.Itoa(port), "GATEWAY", name, portName, "PORT")
+			}
+			for i, port := range service.Ports {
+				if !named[port] {
+					set(strconv.Itoa(port), "GATEWAY", name, strconv.Itoa(i), "PORT")
+				}
+			}
+		case service.Kind == "connect-proxy":
+			for upstream, port := range service.NamedPorts {
+				set(strconv.Itoa(port), "UPSTREAM", name, upstream, "PORT")
+			}
+		}
+	}
+
+	environment := []envVariable{}
+	for name, value := range variables {
+		environment = append(environment, envVariable{Name: name, Value: value})
+	}
+	sort.SliceStable(environment, func(i, j int) bool {
+		return environment[i].Name < environment[j].Name
+	})
+	return environment
+}
+
+// envName joins the parts into a valid environment variable name
+func envName(parts ...string) string {
+	return strings.Trim(invalidEnvCharacters.ReplaceAllString(strings.ToUpper(strings.Join(parts, "_")), "_"), "_")
+}
+
+func writeShellEnv(w io.Writer, variables []envVariable) error {
+	for _, variable := range variables {
+		if _, err := fmt.Fprintf(w, "export %s=%q\n", variable.Name, variable.Value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeFishEnv(w io.Writer, variables []envVariable) error {
+	for _, variable := range variables {
+		if _, err := fmt.Fprintf(w, "set -gx %s %q;\n", variable.Name, variable.Value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeDotEnv(w io.Writer, variables []envVariable) error {
+	for _, variable := range variables {
+		if _, err := fmt.Fprintf(w, "%s=%s\n", variable.Name, variable.Value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeJSONEnv(w io.Writer, variables []envVariable) error {
+	values := map[string]string{}
+	for _, variable := range variables {
+		values[variable.Name] = variable.Value
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(values)
+}
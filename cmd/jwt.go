@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/andrewstucki/consul-services/pkg/server"
+	"github.com/spf13/cobra"
+)
+
+var (
+	jwtClaims string
+	jwtTTL    string
+)
+
+// jwtCmd represents the jwt command
+var jwtCmd = &cobra.Command{
+	Use:   "jwt",
+	Short: "Interacts with the local identity provider used for jwt-provider config entries",
+}
+
+// jwtMintCmd represents the jwt mint command
+var jwtMintCmd = &cobra.Command{
+	Use:   "mint",
+	Short: "Mints a token signed by the local identity provider",
+	Args:  cobra.MatchAll(cobra.NoArgs),
+	Run: func(cmd *cobra.Command, args []string) {
+		logger := createLogger()
+
+		claims := map[string]interface{}{}
+		if jwtClaims != "" {
+			if err := json.Unmarshal([]byte(jwtClaims), &claims); err != nil {
+				logger.Error("claims must be a JSON object", "err", err)
+				os.Exit(1)
+			}
+		}
+
+		client := server.NewClient(socket)
+		token, err := client.MintJWT(claims, jwtTTL)
+		if err != nil {
+			logger.Error("unable to mint token", "err", err)
+			os.Exit(1)
+		}
+
+		fmt.Println(token)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(jwtCmd)
+	jwtCmd.AddCommand(jwtMintCmd)
+
+	jwtMintCmd.Flags().StringVar(&jwtClaims, "claims", "", `Claims to add to the token as a JSON object, i.e. '{"sub": "user", "aud": "api"}'.`)
+	jwtMintCmd.Flags().StringVar(&jwtTTL, "ttl", "1h", "How long until the token expires.")
+}
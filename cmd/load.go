@@ -0,0 +1,139 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/andrewstucki/consul-services/pkg/load"
+	"github.com/andrewstucki/consul-services/pkg/server"
+	"github.com/andrewstucki/consul-services/pkg/tables"
+	"github.com/spf13/cobra"
+)
+
+var (
+	loadGateway     string
+	loadHost        string
+	loadPath        string
+	loadRPS         int
+	loadConcurrency int
+	loadDuration    time.Duration
+	loadTimeout     time.Duration
+)
+
+// loadCmd represents the load command
+var loadCmd = &cobra.Command{
+	Use:   "load [name] [upstream]",
+	Short: "Generates sustained traffic through a service's upstream or a gateway",
+	Args:  cobra.MatchAll(cobra.MaximumNArgs(2)),
+	Run: func(cmd *cobra.Command, args []string) {
+		logger := createLogger()
+		client := server.NewClient(socket)
+
+		config := load.Config{
+			Protocol:    "http",
+			Host:        loadHost,
+			Path:        loadPath,
+			RPS:         loadRPS,
+			Concurrency: loadConcurrency,
+			Duration:    loadDuration,
+			Timeout:     loadTimeout,
+		}
+
+		switch {
+		case loadGateway != "" && len(args) == 0:
+			_, port, err := resolveGatewayPort(client, loadGateway)
+			if err != nil {
+				logger.Error("unable to resolve gateway", "err", err)
+				os.Exit(1)
+			}
+			config.Address = fmt.Sprintf("localhost:%d", port)
+		case loadGateway == "" && len(args) == 2:
+			name := args[0]
+			upstream := args[1]
+
+			// normalize the name to add the -proxy so that we know we're querying
+			// the upstream port from the connect proxy rather than from the service
+			// itself
+			if !strings.HasSuffix(name, "-proxy") {
+				name += "-proxy"
+			}
+
+			service, err := client.Get("connect-proxy", name)
+			if err != nil {
+				logger.Error("unable to fetch service", "err", err)
+				os.Exit(1)
+			}
+
+			port, ok := service.NamedPorts[upstream]
+			if !ok {
+				logger.Error("service does not have upstream defined", "upstream", upstream)
+				os.Exit(1)
+			}
+			config.Address = fmt.Sprintf("localhost:%d", port)
+			config.Protocol = upstreamProtocol(service, upstream)
+		default:
+			logger.Error("either a service and upstream or --gateway must be specified")
+			os.Exit(1)
+		}
+
+		if err := config.Validate(); err != nil {
+			logger.Error("invalid load configuration", "err", err)
+			os.Exit(1)
+		}
+
+		ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer cancel()
+
+		logger.Info("generating load", "address", config.Address, "rps", config.RPS, "concurrency", config.Concurrency, "duration", config.Duration)
+		report := load.New(config).Run(ctx)
+
+		printLoadReport(report)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(loadCmd)
+
+	loadCmd.Flags().StringVarP(&loadGateway, "gateway", "g", "", "Send traffic to a gateway port rather than an upstream, i.e. api:one.")
+	loadCmd.Flags().StringVar(&loadHost, "host", "", "Host header to send with HTTP requests.")
+	loadCmd.Flags().StringVar(&loadPath, "path", "/", "Path to send HTTP requests to.")
+	loadCmd.Flags().IntVar(&loadRPS, "rps", 10, "Requests per second to send, 0 sends as fast as possible.")
+	loadCmd.Flags().IntVar(&loadConcurrency, "concurrency", 4, "Maximum number of in-flight requests.")
+	loadCmd.Flags().DurationVar(&loadDuration, "duration", 10*time.Second, "How long to send traffic for.")
+	loadCmd.Flags().DurationVar(&loadTimeout, "timeout", 5*time.Second, "Timeout for each individual request.")
+}
+
+func printLoadReport(report *load.Report) {
+	fmt.Printf("Requests: %d, Errors: %d, Elapsed: %s, RPS: %.1f\n", report.Total, report.Errors, report.Elapsed.Round(time.Millisecond), report.RPS())
+	fmt.Printf("Latency: p50 %s, p90 %s, p99 %s\n\n", report.P50, report.P90, report.P99)
+
+	histogram := []tables.Bucket{}
+	for i, count := range report.Histogram {
+		label := "+Inf"
+		if i < len(load.Buckets) {
+			label = "<= " + load.Buckets[i].String()
+		}
+		histogram = append(histogram, tables.Bucket{Label: label, Count: count})
+	}
+	tables.PrintDistribution(os.Stdout, "Latency", histogram)
+	fmt.Println()
+
+	tables.PrintDistribution(os.Stdout, "Status", tables.SortedBuckets(report.Statuses))
+	fmt.Println()
+
+	tables.PrintDistribution(os.Stdout, "Instance", tables.SortedBuckets(report.Instances))
+
+	if len(report.Groups) > 0 {
+		fmt.Println()
+		tables.PrintDistribution(os.Stdout, "Group", tables.SortedBuckets(report.Groups))
+	}
+
+	if len(report.ErrorMessages) > 0 {
+		fmt.Println()
+		tables.PrintDistribution(os.Stdout, "Error", tables.SortedBuckets(report.ErrorMessages))
+	}
+}
@@ -1,45 +1,181 @@
 package cmd
 
 import (
-	"io"
+	"context"
+	"errors"
+	"fmt"
 	"os"
+	"os/signal"
+	"regexp"
+	"time"
 
+	"github.com/andrewstucki/consul-services/pkg/logs"
 	"github.com/andrewstucki/consul-services/pkg/server"
+	"github.com/fatih/color"
 	"github.com/spf13/cobra"
 )
 
+var (
+	logKinds   []string
+	allLogs    bool
+	followLogs bool
+	logsSince  time.Duration
+	logsGrep   string
+
+	defaultLogKinds = []string{"connect-proxy"}
+
+	logColors = []*color.Color{
+		color.New(color.FgHiCyan),
+		color.New(color.FgHiMagenta),
+		color.New(color.FgHiYellow),
+		color.New(color.FgHiGreen),
+		color.New(color.FgHiBlue),
+		color.New(color.FgHiRed),
+		color.New(color.FgCyan),
+		color.New(color.FgMagenta),
+		color.New(color.FgYellow),
+		color.New(color.FgGreen),
+	}
+)
+
 // logsCmd represents the logs command
 var logsCmd = &cobra.Command{
 	Use:   "logs [name]",
 	Short: "Read logs from a deployed service.",
-	Args:  cobra.MatchAll(cobra.ExactArgs(1)),
+	Args:  cobra.MatchAll(cobra.MaximumNArgs(1)),
 	Run: func(cmd *cobra.Command, args []string) {
-		name := args[0]
 		logger := createLogger()
 
+		if allLogs && len(args) > 0 {
+			logger.Error("a service name cannot be specified with --all")
+			os.Exit(1)
+		}
+
+		name := ""
+		if len(args) > 0 {
+			name = args[0]
+		}
+
 		client := server.NewClient(socket)
-		service, err := client.Get(kind, name)
+		sources, err := logSources(client, name)
 		if err != nil {
-			logger.Error("unable to fetch service", "err", err)
+			logger.Error("unable to fetch services", "err", err)
 			os.Exit(1)
 		}
-		if service.Logs != "" {
-			logFile, err := os.Open(service.Logs)
-			if err != nil {
-				logger.Error("unable to open logs", "err", err)
-				os.Exit(1)
-			}
-			_, err = io.Copy(os.Stdout, logFile)
+		if len(sources) == 0 {
+			logger.Error("no logs found")
+			os.Exit(1)
+		}
+
+		options := logs.Options{
+			Follow: followLogs,
+		}
+		if logsSince > 0 {
+			options.Since = time.Now().Add(-logsSince)
+		}
+		if logsGrep != "" {
+			options.Grep, err = regexp.Compile(logsGrep)
 			if err != nil {
-				logger.Error("unable to read logs", "err", err)
+				logger.Error("invalid grep expression", "err", err)
 				os.Exit(1)
 			}
 		}
+
+		ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer cancel()
+
+		printer := newLogPrinter(sources, len(sources) > 1)
+		if err := logs.Stream(ctx, sources, options, printer.print); err != nil {
+			logger.Error("unable to read logs", "err", err)
+			os.Exit(1)
+		}
 	},
 }
 
 func init() {
 	rootCmd.AddCommand(logsCmd)
 
-	logsCmd.Flags().StringVarP(&kind, "kind", "k", "connect-proxy", "Kind of service to lookup.")
+	logsCmd.Flags().StringSliceVarP(&logKinds, "kind", "k", defaultLogKinds, "Kinds of service to read logs from.")
+	logsCmd.Flags().BoolVarP(&allLogs, "all", "a", false, "Read logs from every running component, including Consul.")
+	logsCmd.Flags().BoolVarP(&followLogs, "follow", "f", false, "Follow the logs as they are written.")
+	logsCmd.Flags().DurationVar(&logsSince, "since", 0, "Only show logs written within the given duration, i.e. 5m.")
+	logsCmd.Flags().StringVar(&logsGrep, "grep", "", "Only show log lines matching the given regular expression.")
+}
+
+func logSources(client *server.Client, name string) ([]logs.Source, error) {
+	kinds := logKinds
+	if allLogs {
+		kinds = nil
+	}
+
+	services, err := client.List(kinds...)
+	if err != nil {
+		return nil, err
+	}
+
+	sources := []logs.Source{}
+	if allLogs {
+		consuls, err := client.ListConsuls()
+		if err != nil {
+			return nil, err
+		}
+		for _, consul := range consuls {
+			if consul.Logs == "" {
+				continue
+			}
+			sources = append(sources, logs.Source{
+				Name: "consul/" + consul.Datacenter,
+				Path: consul.Logs,
+			})
+		}
+	}
+
+	for _, service := range services {
+		if service.Logs == "" {
+			continue
+		}
+		if name != "" && service.Name != name {
+			continue
+		}
+		sources = append(sources, logs.Source{
+			Name: service.Kind + "/" + service.Name,
+			Path: service.Logs,
+		})
+	}
+
+	if name != "" && len(sources) == 0 {
+		return nil, errors.New("service not found")
+	}
+
+	return sources, nil
+}
+
+type logPrinter struct {
+	multiplexed bool
+	colors      map[string]*color.Color
+	width       int
+}
+
+func newLogPrinter(sources []logs.Source, multiplexed bool) *logPrinter {
+	printer := &logPrinter{
+		multiplexed: multiplexed,
+		colors:      make(map[string]*color.Color),
+	}
+	for i, source := range sources {
+		printer.colors[source.Name] = logColors[i%len(logColors)]
+		if len(source.Name) > printer.width {
+			printer.width = len(source.Name)
+		}
+	}
+	return printer
+}
+
+func (p *logPrinter) print(line logs.Line) {
+	if !p.multiplexed {
+		fmt.Println(line.Text)
+		return
+	}
+
+	prefix := fmt.Sprintf("%s %-*s |", line.Time.Format("15:04:05.000"), p.width, line.Source)
+	fmt.Println(p.colors[line.Source].Sprint(prefix), line.Text)
 }
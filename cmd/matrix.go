@@ -0,0 +1,250 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/andrewstucki/consul-services/pkg/server"
+	"github.com/andrewstucki/consul-services/pkg/tables"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	resultAllowed = "allowed"
+	resultDenied  = "denied"
+	resultError   = "error"
+)
+
+var (
+	matrixExpected    string
+	matrixSave        string
+	matrixConcurrency int
+	matrixTimeout     time.Duration
+)
+
+// connectivityMatrix maps sources to their upstreams and the result of probing them
+type connectivityMatrix map[string]map[string]string
+
+// matrixCmd represents the matrix command
+var matrixCmd = &cobra.Command{
+	Use:   "matrix",
+	Short: "Checks connectivity between every service and each of its upstreams",
+	Args:  cobra.MatchAll(cobra.NoArgs),
+	Run: func(cmd *cobra.Command, args []string) {
+		logger := createLogger()
+
+		if matrixConcurrency <= 0 {
+			logger.Error("concurrency must be greater than or equal to 1")
+			os.Exit(1)
+		}
+
+		client := server.NewClient(socket)
+		proxies, err := client.List("connect-proxy")
+		if err != nil {
+			logger.Error("unable to fetch services", "err", err)
+			os.Exit(1)
+		}
+
+		results := probeMatrix(proxies, matrixConcurrency, matrixTimeout)
+		tables.PrintConnectivity(os.Stdout, results)
+
+		actual := newConnectivityMatrix(results)
+		if matrixSave != "" {
+			if err := actual.write(matrixSave); err != nil {
+				logger.Error("unable to save matrix", "err", err)
+				os.Exit(1)
+			}
+		}
+
+		if matrixExpected == "" {
+			return
+		}
+
+		expected, err := readConnectivityMatrix(matrixExpected)
+		if err != nil {
+			logger.Error("unable to read expected matrix", "err", err)
+			os.Exit(1)
+		}
+
+		if differences := expected.diff(actual); len(differences) > 0 {
+			for _, difference := range differences {
+				logger.Error("unexpected connectivity", "source", difference.Source, "upstream", difference.Upstream, "expected", difference.Expected, "actual", difference.Actual)
+			}
+			os.Exit(checkFailedCode)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(matrixCmd)
+
+	matrixCmd.Flags().StringVarP(&matrixExpected, "expected", "e", "", "Path to a YAML file of expected results to compare against.")
+	matrixCmd.Flags().StringVar(&matrixSave, "save", "", "Path to write the results to in the same format as --expected.")
+	matrixCmd.Flags().IntVar(&matrixConcurrency, "concurrency", 10, "Number of upstreams to probe at once.")
+	matrixCmd.Flags().DurationVar(&matrixTimeout, "timeout", 5*time.Second, "Timeout for each individual probe.")
+}
+
+func probeMatrix(proxies []server.Service, concurrency int, timeout time.Duration) []tables.ConnectivityResult {
+	results := []tables.ConnectivityResult{}
+	for _, proxy := range proxies {
+		for upstream := range proxy.NamedPorts {
+			results = append(results, tables.ConnectivityResult{
+				Source:   strings.TrimSuffix(proxy.Name, "-proxy"),
+				Upstream: upstream,
+			})
+		}
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].Source != results[j].Source {
+			return results[i].Source < results[j].Source
+		}
+		return results[i].Upstream < results[j].Upstream
+	})
+
+	proxiesByName := make(map[string]*server.Service)
+	for i := range proxies {
+		proxiesByName[strings.TrimSuffix(proxies[i].Name, "-proxy")] = &proxies[i]
+	}
+
+	var wg sync.WaitGroup
+	semaphore := make(chan struct{}, concurrency)
+	for i := range results {
+		result := &results[i]
+		proxy := proxiesByName[result.Source]
+
+		wg.Add(1)
+		semaphore <- struct{}{}
+		go func() {
+			defer func() {
+				<-semaphore
+				wg.Done()
+			}()
+
+			start := time.Now()
+			response, err := checkConnectivity(upstreamProtocol(proxy, result.Upstream), fmt.Sprintf("localhost:%d", proxy.NamedPorts[result.Upstream]), nil, timeout)
+			result.Latency = time.Since(start)
+			result.Result, result.Detail = classifyProbe(response, err)
+		}()
+	}
+	wg.Wait()
+
+	return results
+}
+
+// classifyProbe determines whether a probe was allowed, denied by an intention, or failed
+func classifyProbe(result *checkResult, err error) (string, string) {
+	switch {
+	case err == nil && result.Status == http.StatusForbidden:
+		// envoy's L7 RBAC filter
+		return resultDenied, strings.TrimSpace(result.Body)
+	case err == nil && result.Status == 0 && result.Body == "":
+		// envoy's L4 RBAC filter closes the connection without writing anything
+		return resultDenied, "connection closed"
+	case err == nil:
+		return resultAllowed, result.Instance
+	case errors.Is(err, syscall.ECONNRESET), errors.Is(err, io.EOF):
+		return resultDenied, err.Error()
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return resultError, "timeout"
+	}
+	return resultError, err.Error()
+}
+
+func newConnectivityMatrix(results []tables.ConnectivityResult) connectivityMatrix {
+	matrix := make(connectivityMatrix)
+	for _, result := range results {
+		if _, ok := matrix[result.Source]; !ok {
+			matrix[result.Source] = make(map[string]string)
+		}
+		matrix[result.Source][result.Upstream] = result.Result
+	}
+	return matrix
+}
+
+func readConnectivityMatrix(file string) (connectivityMatrix, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	matrix := make(connectivityMatrix)
+	if err := yaml.Unmarshal(data, &matrix); err != nil {
+		return nil, err
+	}
+
+	for source, upstreams := range matrix {
+		for upstream, result := range upstreams {
+			switch result {
+			case resultAllowed, resultDenied, resultError:
+			default:
+				return nil, fmt.Errorf("invalid result %q for %s -> %s", result, source, upstream)
+			}
+		}
+	}
+	return matrix, nil
+}
+
+func (m connectivityMatrix) write(file string) error {
+	data, err := yaml.Marshal(m)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(file, data, 0644)
+}
+
+// matrixDifference is a single pair whose result doesn't match what was expected
+type matrixDifference struct {
+	Source   string
+	Upstream string
+	Expected string
+	Actual   string
+}
+
+// diff returns the differences between the expected matrix and the actual one,
+// pairs missing from either side are reported with an empty result
+func (m connectivityMatrix) diff(actual connectivityMatrix) []matrixDifference {
+	differences := []matrixDifference{}
+
+	pairs := map[[2]string]struct{}{}
+	for _, matrix := range []connectivityMatrix{m, actual} {
+		for source, upstreams := range matrix {
+			for upstream := range upstreams {
+				pairs[[2]string{source, upstream}] = struct{}{}
+			}
+		}
+	}
+
+	for pair := range pairs {
+		expected := m[pair[0]][pair[1]]
+		got := actual[pair[0]][pair[1]]
+		if expected != got {
+			differences = append(differences, matrixDifference{
+				Source:   pair[0],
+				Upstream: pair[1],
+				Expected: expected,
+				Actual:   got,
+			})
+		}
+	}
+
+	sort.SliceStable(differences, func(i, j int) bool {
+		if differences[i].Source != differences[j].Source {
+			return differences[i].Source < differences[j].Source
+		}
+		return differences[i].Upstream < differences[j].Upstream
+	})
+	return differences
+}
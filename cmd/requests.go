@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"encoding/json"
+	"os"
+
+	"github.com/andrewstucki/consul-services/pkg/server"
+	"github.com/andrewstucki/consul-services/pkg/tables"
+	"github.com/spf13/cobra"
+)
+
+var (
+	requestsJSON bool
+)
+
+// requestsCmd represents the requests command
+var requestsCmd = &cobra.Command{
+	Use:   "requests [name]",
+	Short: "Shows the requests most recently received by a service.",
+	Args:  cobra.MatchAll(cobra.ExactArgs(1)),
+	Run: func(cmd *cobra.Command, args []string) {
+		name := args[0]
+		logger := createLogger()
+
+		client := server.NewClient(socket)
+		requests, err := client.GetRequests(kind, name)
+		if err != nil {
+			logger.Error("unable to fetch requests", "err", err)
+			os.Exit(1)
+		}
+
+		if requestsJSON {
+			encoder := json.NewEncoder(os.Stdout)
+			encoder.SetIndent("", "  ")
+			if err := encoder.Encode(requests); err != nil {
+				logger.Error("unable to encode requests", "err", err)
+				os.Exit(1)
+			}
+			return
+		}
+
+		tables.PrintRequests(os.Stdout, requests)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(requestsCmd)
+
+	requestsCmd.Flags().StringVarP(&kind, "kind", "k", defaultKind, "Kind of service to lookup.")
+	requestsCmd.Flags().BoolVar(&requestsJSON, "json", false, "Output the full requests, including headers, as JSON.")
+}
@@ -0,0 +1,57 @@
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/andrewstucki/consul-services/pkg/server"
+)
+
+var gatewayKinds = map[string]bool{
+	"api-gateway":         true,
+	"ingress-gateway":     true,
+	"terminating-gateway": true,
+}
+
+// findGateway looks up a gateway by name regardless of its kind
+func findGateway(client *server.Client, name string) (*server.Service, error) {
+	services, err := client.List()
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range services {
+		service := services[i]
+		if gatewayKinds[service.Kind] && service.Name == name {
+			return &service, nil
+		}
+	}
+
+	return nil, fmt.Errorf("gateway %q not found", name)
+}
+
+// resolveGatewayPort resolves a target of the form [name]:[port] to a
+// gateway port, where port is either a named port or an index into the
+// gateway's allocated ports
+func resolveGatewayPort(client *server.Client, target string) (*server.Service, int, error) {
+	name, portName, found := strings.Cut(target, ":")
+	if !found || name == "" || portName == "" {
+		return nil, 0, fmt.Errorf("gateway must be of the form [name]:[port], got %q", target)
+	}
+
+	gateway, err := findGateway(client, name)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if port, ok := gateway.NamedPorts[portName]; ok {
+		return gateway, port, nil
+	}
+
+	index, err := strconv.Atoi(portName)
+	if err != nil || index < 0 || index >= len(gateway.Ports) {
+		return nil, 0, fmt.Errorf("gateway %q does not have port %q", name, portName)
+	}
+	return gateway, gateway.Ports[index], nil
+}
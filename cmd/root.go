@@ -2,10 +2,13 @@ package cmd
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"os"
 	"os/signal"
 	"path"
 	"strconv"
+	"strings"
 
 	"github.com/andrewstucki/consul-services/pkg"
 	"github.com/andrewstucki/consul-services/pkg/daemonize"
@@ -22,19 +25,39 @@ const (
 var (
 	defaultUnixSocket string
 
-	tcpServiceCount          int
-	httpServiceCount         int
-	httpExternalServiceCount int
-	tcpExternalServiceCount  int
-	duplicateServiceCount    int
-	resourceFolder           string
-	consulBinary             string
-	socket                   string
-	output                   string
-	configFile               string
-	datacenters              []string
-	runConsul                bool
-	daemonizeRunner          bool
+	tcpServiceCount           int
+	httpServiceCount          int
+	httpExternalServiceCount  int
+	tcpExternalServiceCount   int
+	http2ServiceCount         int
+	grpcServiceCount          int
+	http2ExternalServiceCount int
+	grpcExternalServiceCount  int
+	nativeServiceCount        int
+	duplicateServiceCount     int
+	resourceFolder            string
+	consulBinary              string
+	socket                    string
+	output                    string
+	configFile                string
+	datacenters               []string
+	caDirectory               string
+	region                    string
+	zones                     []string
+	versions                  []string
+	calls                     []string
+	sidecars                  []string
+	apiGateways               []string
+	gatewayRoutes             []string
+	dataplaneBinary           string
+	meshGatewayMode           string
+	runConsul                 bool
+	runExtAuthz               bool
+	terminatingGateway        bool
+	terminatingGatewayTLS     bool
+	wanFederation             bool
+	runChaos                  bool
+	daemonizeRunner           bool
 )
 
 func setCommandFlag(cmd *cobra.Command, flag string) {
@@ -79,14 +102,30 @@ var rootCmd = &cobra.Command{
 		setCommandFlag(cmd, "duplicates")
 		setCommandFlag(cmd, "resources")
 		setCommandFlag(cmd, "consul")
+		setCommandFlag(cmd, "dataplane")
 		setCommandFlag(cmd, "socket")
 		setCommandFlag(cmd, "run")
+		setCommandFlag(cmd, "ext-authz")
+		setCommandFlag(cmd, "terminating-gateway")
+		setCommandFlag(cmd, "terminating-gateway-tls")
+		setCommandFlag(cmd, "chaos")
+		setCommandFlagExtended(cmd, "mesh-gateways.mode", "mesh-gateway-mode")
+		setCommandFlagExtended(cmd, "mesh-gateways.wan-federation", "wan-federation")
+		setCommandFlagExtended(cmd, "ca", "ca-dir")
 
 		setCommandFlagArray(cmd, "datacenters", "datacenter")
+		setCommandFlagExtended(cmd, "locality.region", "region")
+		setCommandFlagArray(cmd, "locality.zones", "zone")
+		setCommandFlagArray(cmd, "versions", "versions")
 		setCommandFlagExtended(cmd, "services.tcp", "tcp")
 		setCommandFlagExtended(cmd, "services.http", "http")
 		setCommandFlagExtended(cmd, "services.external.tcp", "external-tcp")
 		setCommandFlagExtended(cmd, "services.external.http", "external-http")
+		setCommandFlagExtended(cmd, "services.http2", "http2")
+		setCommandFlagExtended(cmd, "services.grpc", "grpc")
+		setCommandFlagExtended(cmd, "services.external.http2", "external-http2")
+		setCommandFlagExtended(cmd, "services.external.grpc", "external-grpc")
+		setCommandFlagExtended(cmd, "services.native", "native")
 
 		return nil
 	},
@@ -96,18 +135,67 @@ var rootCmd = &cobra.Command{
 
 		logger := createLogger()
 
+		instanceGroups, err := readInstanceGroups()
+		if err != nil {
+			logger.Error("error reading instance groups", "err", err)
+			retcode = 1
+			return
+		}
+
+		serviceCalls, err := readCalls()
+		if err != nil {
+			logger.Error("error reading calls", "err", err)
+			retcode = 1
+			return
+		}
+
+		serviceSidecars, err := readSidecars()
+		if err != nil {
+			logger.Error("error reading sidecars", "err", err)
+			retcode = 1
+			return
+		}
+
+		gateways, routes, err := readAPIGateways()
+		if err != nil {
+			logger.Error("error reading gateways", "err", err)
+			retcode = 1
+			return
+		}
+
 		config := pkg.RunnerConfig{
-			TCPServiceCount:          tcpServiceCount,
-			HTTPServiceCount:         httpServiceCount,
-			ExternalTCPServiceCount:  tcpExternalServiceCount,
-			ExternalHTTPServiceCount: httpExternalServiceCount,
-			ServiceDuplicates:        duplicateServiceCount,
-			ResourceFolder:           resourceFolder,
-			ConsulBinary:             consulBinary,
-			Socket:                   socket,
-			RunConsul:                runConsul,
-			Datacenters:              datacenters,
-			Logger:                   logger,
+			TCPServiceCount:           tcpServiceCount,
+			HTTPServiceCount:          httpServiceCount,
+			ExternalTCPServiceCount:   tcpExternalServiceCount,
+			ExternalHTTPServiceCount:  httpExternalServiceCount,
+			HTTP2ServiceCount:         http2ServiceCount,
+			GRPCServiceCount:          grpcServiceCount,
+			ExternalHTTP2ServiceCount: http2ExternalServiceCount,
+			ExternalGRPCServiceCount:  grpcExternalServiceCount,
+			NativeServiceCount:        nativeServiceCount,
+			ServiceDuplicates:         duplicateServiceCount,
+			ResourceFolder:            resourceFolder,
+			ConsulBinary:              consulBinary,
+			DataplaneBinary:           dataplaneBinary,
+			Socket:                    socket,
+			RunConsul:                 runConsul,
+			Datacenters:               datacenters,
+			Region:                    region,
+			Zones:                     zones,
+			InstanceGroups:            instanceGroups,
+			Calls:                     serviceCalls,
+			Sidecars:                  serviceSidecars,
+			APIGateways:               gateways,
+			GatewayRoutes:             routes,
+			Logger:                    logger,
+
+			CertificateAuthorityDirectory: caDirectory,
+			ExtAuthz:                      runExtAuthz,
+			TerminatingGateway:            terminatingGateway,
+			TerminatingGatewayTLS:         terminatingGatewayTLS,
+			MeshGatewayMode:               meshGatewayMode,
+			WANFederation:                 wanFederation,
+			Chaos:                         runChaos,
 		}
 
 		if err := config.Validate(); err != nil {
@@ -181,18 +269,54 @@ func init() {
 	viper.BindPFlag("services.external.http", rootCmd.Flags().Lookup("external-http"))
 	rootCmd.Flags().IntVar(&tcpExternalServiceCount, "external-tcp", 0, "Number of TCP-based external services to register on the mesh.")
 	viper.BindPFlag("services.external.tcp", rootCmd.Flags().Lookup("external-tcp"))
+	rootCmd.Flags().IntVar(&http2ServiceCount, "http2", 0, "Number of HTTP/2-based services to register on the mesh.")
+	viper.BindPFlag("services.http2", rootCmd.Flags().Lookup("http2"))
+	rootCmd.Flags().IntVar(&grpcServiceCount, "grpc", 0, "Number of gRPC-based services to register on the mesh.")
+	viper.BindPFlag("services.grpc", rootCmd.Flags().Lookup("grpc"))
+	rootCmd.Flags().IntVar(&http2ExternalServiceCount, "external-http2", 0, "Number of HTTP/2-based external services to register on the mesh.")
+	viper.BindPFlag("services.external.http2", rootCmd.Flags().Lookup("external-http2"))
+	rootCmd.Flags().IntVar(&grpcExternalServiceCount, "external-grpc", 0, "Number of gRPC-based external services to register on the mesh.")
+	viper.BindPFlag("services.external.grpc", rootCmd.Flags().Lookup("external-grpc"))
+	rootCmd.Flags().IntVar(&nativeServiceCount, "native", 0, "Number of connect-native HTTP-based services to register on the mesh.")
+	viper.BindPFlag("services.native", rootCmd.Flags().Lookup("native"))
 	rootCmd.Flags().IntVarP(&duplicateServiceCount, "duplicates", "D", 1, "Number of duplicate services to register on the mesh.")
 	viper.BindPFlag("duplicates", rootCmd.Flags().Lookup("duplicates"))
 	rootCmd.Flags().StringVarP(&resourceFolder, "resources", "r", "", "Path to a folder containing extra configuration entries to write.")
 	viper.BindPFlag("resources", rootCmd.Flags().Lookup("resources"))
 	rootCmd.Flags().StringVar(&consulBinary, "consul", "", "Consul binary to use for registration, defaults to a binary found in the current folder and then the PATH.")
 	viper.BindPFlag("consul", rootCmd.Flags().Lookup("consul"))
+	rootCmd.Flags().StringVar(&dataplaneBinary, "dataplane", "", "consul-dataplane binary to use for dataplane sidecars, defaults to a binary found in the current folder and then the PATH.")
+	viper.BindPFlag("dataplane", rootCmd.Flags().Lookup("dataplane"))
 	rootCmd.PersistentFlags().StringVarP(&socket, "socket", "s", "", "Path to unix socket for control server. (default \"$HOME/.consul-services.sock\")")
 	viper.BindPFlag("socket", rootCmd.PersistentFlags().Lookup("socket"))
 	rootCmd.Flags().BoolVar(&runConsul, "run", false, "Additionally run Consul binary in agent mode.")
 	viper.BindPFlag("run", rootCmd.Flags().Lookup("run"))
+	rootCmd.Flags().BoolVar(&runExtAuthz, "ext-authz", false, "Additionally run an ext_authz service on the mesh whose policy is managed with the authz command.")
+	viper.BindPFlag("ext-authz", rootCmd.Flags().Lookup("ext-authz"))
+	rootCmd.Flags().BoolVar(&terminatingGateway, "terminating-gateway", false, "Additionally run a terminating gateway in each datacenter for the external services.")
+	viper.BindPFlag("terminating-gateway", rootCmd.Flags().Lookup("terminating-gateway"))
+	rootCmd.Flags().BoolVar(&terminatingGatewayTLS, "terminating-gateway-tls", false, "Serve external services over TLS with certificates from the built-in CA, verified by the terminating gateway.")
+	viper.BindPFlag("terminating-gateway-tls", rootCmd.Flags().Lookup("terminating-gateway-tls"))
+	rootCmd.Flags().StringVar(&meshGatewayMode, "mesh-gateway-mode", "local", "Mesh gateway mode written to the proxy-defaults of multi-datacenter runs, one of local, remote or none.")
+	viper.BindPFlag("mesh-gateways.mode", rootCmd.Flags().Lookup("mesh-gateway-mode"))
+	rootCmd.Flags().BoolVar(&wanFederation, "wan-federation", false, "Federate multiple datacenters through their mesh gateways rather than joining their agents over the WAN.")
+	viper.BindPFlag("mesh-gateways.wan-federation", rootCmd.Flags().Lookup("wan-federation"))
+	rootCmd.Flags().BoolVar(&runChaos, "chaos", false, "Run a proxy between each service and its sidecar that can inject network faults with the chaos command.")
+	viper.BindPFlag("chaos", rootCmd.Flags().Lookup("chaos"))
 	rootCmd.Flags().StringArrayVar(&datacenters, "datacenter", []string{"dc1"}, "Datacenters to deploy into.")
 	viper.BindPFlag("datacenters", rootCmd.Flags().Lookup("datacenter"))
+	rootCmd.Flags().StringVar(&region, "region", "", "Region to register agents and services in for locality-aware routing.")
+	viper.BindPFlag("locality.region", rootCmd.Flags().Lookup("region"))
+	rootCmd.Flags().StringArrayVar(&zones, "zone", nil, "Zones within the region to spread duplicate services across round-robin.")
+	viper.BindPFlag("locality.zones", rootCmd.Flags().Lookup("zone"))
+	rootCmd.Flags().StringSliceVar(&versions, "versions", nil, "Versions to spread duplicate services across round-robin, each is added as a tag and version metadata.")
+	viper.BindPFlag("versions", rootCmd.Flags().Lookup("versions"))
+	rootCmd.Flags().StringArrayVar(&calls, "call", nil, "Upstreams a service calls when it receives a request, i.e. http-1=http-2,http-external-1.")
+	rootCmd.Flags().StringArrayVar(&apiGateways, "api-gateway", nil, "API gateway to generate with listeners named after their protocol, one of http, https or tcp, i.e. api=http,https.")
+	rootCmd.Flags().StringArrayVar(&gatewayRoutes, "route", nil, "Route to generate on an API gateway with an optional hostname and path prefix, i.e. api:test.consul.local/path=http-1,http-2 or api=tcp-1.")
+	rootCmd.Flags().StringArrayVar(&sidecars, "sidecar", nil, "Sidecar to run for a service instead of envoy, either builtin or dataplane, i.e. http-1=dataplane.")
+	rootCmd.Flags().StringVar(&caDirectory, "ca-dir", "", "Path to a directory to load the root CA from so it is stable across runs, a CA is generated there if none exists.")
+	viper.BindPFlag("ca", rootCmd.Flags().Lookup("ca-dir"))
 	rootCmd.PersistentFlags().StringVarP(&output, "output", "o", "", "Path to use for output rather than stdout.")
 	viper.BindPFlag("output", rootCmd.PersistentFlags().Lookup("output"))
 
@@ -204,6 +328,135 @@ func init() {
 	viper.SetConfigType("yaml")
 }
 
+// readInstanceGroups returns the instance groups given by --versions or
+// defined in full under the "instances" key of the configuration file
+func readInstanceGroups() ([]pkg.InstanceGroup, error) {
+	groups := pkg.VersionGroups(versions)
+	if !viper.IsSet("instances") {
+		return groups, nil
+	}
+	if len(groups) > 0 {
+		return nil, errors.New("--versions cannot be used along with instance groups in the configuration file")
+	}
+
+	if err := viper.UnmarshalKey("instances", &groups); err != nil {
+		return nil, err
+	}
+	return groups, nil
+}
+
+// readCalls returns the upstreams called by each service given by --call and
+// under the "calls" key of the configuration file
+func readCalls() (map[string][]string, error) {
+	serviceCalls := make(map[string][]string)
+	if viper.IsSet("calls") {
+		if err := viper.UnmarshalKey("calls", &serviceCalls); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, call := range calls {
+		name, upstreams, found := strings.Cut(call, "=")
+		if !found || name == "" || upstreams == "" {
+			return nil, fmt.Errorf("invalid call %q, must be of the form service=upstream,upstream", call)
+		}
+		serviceCalls[name] = append(serviceCalls[name], strings.Split(upstreams, ",")...)
+	}
+	return serviceCalls, nil
+}
+
+// readSidecars returns the sidecar run for each service given by --sidecar and
+// under the "sidecars" key of the configuration file
+func readSidecars() (map[string]string, error) {
+	serviceSidecars := make(map[string]string)
+	if viper.IsSet("sidecars") {
+		if err := viper.UnmarshalKey("sidecars", &serviceSidecars); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, sidecar := range sidecars {
+		name, kind, found := strings.Cut(sidecar, "=")
+		if !found || name == "" || kind == "" {
+			return nil, fmt.Errorf("invalid sidecar %q, must be of the form service=sidecar", sidecar)
+		}
+		serviceSidecars[name] = kind
+	}
+	return serviceSidecars, nil
+}
+
+// readAPIGateways returns the API gateways and routes to generate given by --api-gateway
+// and --route and under the "gateways.api" and "routes" keys of the configuration file
+func readAPIGateways() ([]pkg.APIGateway, []pkg.GatewayRoute, error) {
+	gateways := []pkg.APIGateway{}
+	if viper.IsSet("gateways.api") {
+		if err := viper.UnmarshalKey("gateways.api", &gateways); err != nil {
+			return nil, nil, err
+		}
+	}
+	routes := []pkg.GatewayRoute{}
+	if viper.IsSet("routes") {
+		if err := viper.UnmarshalKey("routes", &routes); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	for _, gateway := range apiGateways {
+		name, listeners, found := strings.Cut(gateway, "=")
+		if !found || name == "" || listeners == "" {
+			return nil, nil, fmt.Errorf("invalid gateway %q, must be of the form gateway=listener,listener", gateway)
+		}
+		gateways = append(gateways, pkg.APIGateway{
+			Name:      name,
+			Listeners: strings.Split(listeners, ","),
+		})
+	}
+
+	for _, route := range gatewayRoutes {
+		// routes are of the form gateway[:hostname][/path]=service,service
+		match, services, found := strings.Cut(route, "=")
+		if !found || services == "" {
+			return nil, nil, fmt.Errorf("invalid route %q, must be of the form gateway[:hostname][/path]=service,service", route)
+		}
+
+		parsed := pkg.GatewayRoute{
+			Services: strings.Split(services, ","),
+		}
+		if index := strings.Index(match, "/"); index >= 0 {
+			match, parsed.Path = match[:index], match[index:]
+		}
+		gateway, hostname, _ := strings.Cut(match, ":")
+		if gateway == "" {
+			return nil, nil, fmt.Errorf("invalid route %q, must be of the form gateway[:hostname][/path]=service,service", route)
+		}
+		parsed.Gateway = gateway
+		if hostname != "" {
+			parsed.Hostnames = []string{hostname}
+		}
+		routes = append(routes, parsed)
+	}
+
+	// gateways in the configuration file can leave out their names, and
+	// routes can leave out their gateway when there's only one
+	for i := range gateways {
+		if gateways[i].Name == "" {
+			gateways[i].Name = "api"
+			if i > 0 {
+				gateways[i].Name = fmt.Sprintf("api-%d", i+1)
+			}
+		}
+	}
+	if len(gateways) == 1 {
+		for i := range routes {
+			if routes[i].Gateway == "" {
+				routes[i].Gateway = gateways[0].Name
+			}
+		}
+	}
+
+	return gateways, routes, nil
+}
+
 func daemonArgs() []string {
 	daemonOut := output
 	if daemonOut == "" {
@@ -214,16 +467,64 @@ func daemonArgs() []string {
 		reexec.Self(),
 		"--tcp", strconv.Itoa(tcpServiceCount),
 		"--http", strconv.Itoa(httpServiceCount),
+		"--http2", strconv.Itoa(http2ServiceCount),
+		"--grpc", strconv.Itoa(grpcServiceCount),
+		"--native", strconv.Itoa(nativeServiceCount),
+		"--external-tcp", strconv.Itoa(tcpExternalServiceCount),
+		"--external-http", strconv.Itoa(httpExternalServiceCount),
+		"--external-http2", strconv.Itoa(http2ExternalServiceCount),
+		"--external-grpc", strconv.Itoa(grpcExternalServiceCount),
 		"--duplicates", strconv.Itoa(duplicateServiceCount),
 		"--resources", resourceFolder,
 		"--socket", socket,
 		"--config", configFile,
 		"--consul", consulBinary,
+		"--dataplane", dataplaneBinary,
+		"--mesh-gateway-mode", meshGatewayMode,
 		"--output", daemonOut,
 	}
 	if runConsul {
 		args = append(args, "--run")
 	}
+	if runExtAuthz {
+		args = append(args, "--ext-authz")
+	}
+	if terminatingGateway {
+		args = append(args, "--terminating-gateway")
+	}
+	if terminatingGatewayTLS {
+		args = append(args, "--terminating-gateway-tls")
+	}
+	if runChaos {
+		args = append(args, "--chaos")
+	}
+	if wanFederation {
+		args = append(args, "--wan-federation")
+	}
+	if caDirectory != "" {
+		args = append(args, "--ca-dir", caDirectory)
+	}
+	if region != "" {
+		args = append(args, "--region", region)
+	}
+	for _, zone := range zones {
+		args = append(args, "--zone", zone)
+	}
+	for _, call := range calls {
+		args = append(args, "--call", call)
+	}
+	for _, sidecar := range sidecars {
+		args = append(args, "--sidecar", sidecar)
+	}
+	for _, gateway := range apiGateways {
+		args = append(args, "--api-gateway", gateway)
+	}
+	for _, route := range gatewayRoutes {
+		args = append(args, "--route", route)
+	}
+	if len(versions) > 0 && !viper.IsSet("instances") {
+		args = append(args, "--versions", strings.Join(versions, ","))
+	}
 
 	return args
 }
@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func TestReadCalls(t *testing.T) {
+	for name, tt := range map[string]struct {
+		flags    []string
+		config   map[string][]string
+		expected map[string][]string
+		err      bool
+	}{
+		"single upstream": {
+			flags:    []string{"http-1=http-2"},
+			expected: map[string][]string{"http-1": {"http-2"}},
+		},
+		"multiple upstreams": {
+			flags:    []string{"http-1=http-2,http-external-1"},
+			expected: map[string][]string{"http-1": {"http-2", "http-external-1"}},
+		},
+		"repeated flags append": {
+			flags:    []string{"http-1=http-2", "http-1=http-3", "http-2=http-3"},
+			expected: map[string][]string{"http-1": {"http-2", "http-3"}, "http-2": {"http-3"}},
+		},
+		"flags append to configuration": {
+			flags:    []string{"http-1=http-3"},
+			config:   map[string][]string{"http-1": {"http-2"}},
+			expected: map[string][]string{"http-1": {"http-2", "http-3"}},
+		},
+		"missing separator": {
+			flags: []string{"http-1"},
+			err:   true,
+		},
+		"missing service": {
+			flags: []string{"=http-2"},
+			err:   true,
+		},
+		"missing upstreams": {
+			flags: []string{"http-1="},
+			err:   true,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			viper.Reset()
+			defer viper.Reset()
+			if tt.config != nil {
+				viper.Set("calls", tt.config)
+			}
+
+			calls = tt.flags
+			defer func() { calls = nil }()
+
+			parsed, err := readCalls()
+			if tt.err {
+				if err == nil {
+					t.Fatalf("expected an error, got: %v", parsed)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(parsed, tt.expected) {
+				t.Fatalf("expected %v, got %v", tt.expected, parsed)
+			}
+		})
+	}
+}
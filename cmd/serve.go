@@ -0,0 +1,99 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/andrewstucki/consul-services/pkg"
+	"github.com/andrewstucki/consul-services/pkg/native"
+	"github.com/hashicorp/consul/api"
+	"github.com/spf13/cobra"
+	"golang.org/x/sync/errgroup"
+)
+
+var (
+	serveProtocol string
+	servePort     int
+	serveID       string
+	serveDC       string
+	serveNative   string
+	serveConsul   string
+)
+
+// serveCmd represents the serve command
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Runs a standalone test service outside of the mesh, used to reproduce runs from a report",
+	Args:  cobra.MatchAll(cobra.NoArgs),
+	Run: func(cmd *cobra.Command, args []string) {
+		logger := createLogger()
+
+		if servePort <= 0 {
+			logger.Error("port must be specified")
+			os.Exit(1)
+		}
+
+		ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer cancel()
+
+		service := &pkg.Service{
+			ID:         serveID,
+			Datacenter: serveDC,
+			Protocol:   serveProtocol,
+			Port:       servePort,
+			Logger:     logger,
+		}
+
+		group, ctx := errgroup.WithContext(ctx)
+		if serveNative != "" {
+			source, err := nativeSource(ctx, serveConsul, serveNative)
+			if err != nil {
+				logger.Error("unable to fetch connect-native certificates", "err", err)
+				os.Exit(1)
+			}
+			service.TLS = source.ServerTLSConfig()
+
+			group.Go(func() error {
+				return source.Watch(ctx)
+			})
+		}
+		group.Go(func() error {
+			return service.Run(ctx)
+		})
+
+		if err := group.Wait(); err != nil {
+			logger.Error("error running service", "err", err)
+			os.Exit(1)
+		}
+	},
+}
+
+// nativeSource fetches the certificates of the connect-native service from the Consul agent
+func nativeSource(ctx context.Context, address, service string) (*native.Source, error) {
+	config := api.DefaultConfig()
+	config.Address = address
+
+	client, err := api.NewClient(config)
+	if err != nil {
+		return nil, err
+	}
+
+	source := native.NewSource(client, service, "", "")
+	if err := source.Fetch(ctx); err != nil {
+		return nil, err
+	}
+	return source, nil
+}
+
+func init() {
+	rootCmd.AddCommand(serveCmd)
+
+	serveCmd.Flags().StringVar(&serveProtocol, "protocol", "http", "Protocol of the service, one of http, http2, grpc or tcp.")
+	serveCmd.Flags().IntVar(&servePort, "port", 0, "Port to serve on.")
+	serveCmd.Flags().StringVar(&serveID, "id", "service", "Id of the service returned in responses.")
+	serveCmd.Flags().StringVar(&serveDC, "datacenter", "dc1", "Datacenter of the service returned in echo responses.")
+	serveCmd.Flags().StringVar(&serveNative, "native", "", "Name of the service in Consul to serve as connect-native, with mTLS certificates from Consul.")
+	serveCmd.Flags().StringVar(&serveConsul, "consul-address", "127.0.0.1:8500", "Address of the Consul agent used by connect-native services.")
+}
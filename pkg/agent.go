@@ -24,6 +24,16 @@ type ConsulAgent struct {
 	// PrimaryDatacenter is the primary datacenter for the federated cluster
 	PrimaryDatacenter string
 
+	// Locality is the region and zone the agent runs in
+	Locality serviceLocality
+
+	// WANFederation federates the datacenters through mesh gateways rather than
+	// by joining the agents over the WAN directly
+	WANFederation bool
+	// PrimaryGateway is the address of the mesh gateway of the primary datacenter, used
+	// by secondary datacenters to federate with it
+	PrimaryGateway string
+
 	// Server used in registering information about the deployed consul instance
 	Server *server.Server
 
@@ -41,12 +51,14 @@ func (c *ConsulAgent) Run(ctx context.Context) error {
 	return c.runConsulBinary(ctx, func(log string) {
 		c.Server.AddConsul(server.Consul{
 			Datacenter: c.Datacenter,
+			Primary:    c.Datacenter == c.PrimaryDatacenter,
 			Ports:      c.tracker.ports,
 			NamedPorts: c.tracker.namedPorts,
 			Logs:       log,
 			Config:     c.configFile(),
 			Address:    c.address(),
 			WanAddress: c.wanAddress(),
+			Federated:  c.WANFederation,
 		})
 	}, commands.AgentRunArgs(vfs.PathFor(c.configFile())))
 }
@@ -71,6 +83,41 @@ func (c *ConsulAgent) writeConfig() error {
 	return c.renderTemplate(agentTemplate, c.configFile())
 }
 
+// writeTLS writes the server certificate for the agent, issued by the built-in CA,
+// which federating through mesh gateways requires
+func (c *ConsulAgent) writeTLS() (*agentTLS, error) {
+	ca, err := CertificateAuthority()
+	if err != nil {
+		return nil, err
+	}
+	serverName := fmt.Sprintf("server.%s.consul", c.Datacenter)
+	certificate, err := generateCertificate(serverName, serverName, "localhost", "127.0.0.1")
+	if err != nil {
+		return nil, err
+	}
+
+	files := map[string]string{
+		"ca.pem":         ca.Certificate,
+		"server.pem":     certificate.Chain,
+		"server-key.pem": certificate.PrivateKey,
+	}
+	for name, data := range files {
+		if err := vfs.WriteFile(c.tlsFile(name), []byte(data), 0600); err != nil {
+			return nil, err
+		}
+	}
+
+	return &agentTLS{
+		CAFile:   vfs.PathFor(c.tlsFile("ca.pem")),
+		CertFile: vfs.PathFor(c.tlsFile("server.pem")),
+		KeyFile:  vfs.PathFor(c.tlsFile("server-key.pem")),
+	}, nil
+}
+
+func (c *ConsulAgent) tlsFile(name string) string {
+	return path.Join(c.Datacenter, "consul", "tls", name)
+}
+
 func (c *ConsulAgent) configFile() string {
 	return path.Join(c.Datacenter, "consul", fmt.Sprintf("config.hcl"))
 }
@@ -112,26 +159,50 @@ func (c *ConsulAgent) wanAddress() string {
 }
 
 func (c *ConsulAgent) renderTemplate(template, name string) error {
-	rendered, err := c.executeTemplate(template)
+	var tls *agentTLS
+	if c.WANFederation {
+		var err error
+		tls, err = c.writeTLS()
+		if err != nil {
+			return err
+		}
+	}
+
+	rendered, err := c.executeTemplate(template, tls)
 	if err != nil {
 		return err
 	}
 	return vfs.WriteFile(name, rendered, 0600)
 }
 
+// agentTLS are the paths of the TLS files an agent uses for RPC
+type agentTLS struct {
+	CAFile   string
+	CertFile string
+	KeyFile  string
+}
+
 type configArgs struct {
 	*tracker
 	PrimaryDatacenter string
 	Datacenter        string
+	Locality          serviceLocality
+	WANFederation     bool
+	PrimaryGateway    string
+	TLS               *agentTLS
 }
 
-func (c *ConsulAgent) executeTemplate(name string) ([]byte, error) {
+func (c *ConsulAgent) executeTemplate(name string, tls *agentTLS) ([]byte, error) {
 	var buffer bytes.Buffer
 
 	if err := getTemplate(name).Execute(&buffer, &configArgs{
 		tracker:           c.tracker,
 		PrimaryDatacenter: c.PrimaryDatacenter,
 		Datacenter:        c.Datacenter,
+		Locality:          c.Locality,
+		WANFederation:     c.WANFederation,
+		PrimaryGateway:    c.PrimaryGateway,
+		TLS:               tls,
 	}); err != nil {
 		return nil, err
 	}
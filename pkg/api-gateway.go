@@ -0,0 +1,197 @@
+package pkg
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/andrewstucki/consul-services/pkg/server"
+	"github.com/hashicorp/consul/api"
+)
+
+const (
+	listenerHTTP  = "http"
+	listenerHTTPS = "https"
+	listenerTCP   = "tcp"
+
+	apiGatewayTemplate        = "api-gateway.hcl"
+	inlineCertificateTemplate = "inline-certificate.hcl"
+	httpRouteTemplate         = "http-route.hcl"
+	tcpRouteTemplate          = "tcp-route.hcl"
+)
+
+// APIGateway is an API gateway generated from configuration rather than from resource files.
+type APIGateway struct {
+	// Name is the name of the gateway
+	Name string
+	// Listeners are the listeners of the gateway, one of http, https or tcp, each is named after
+	// its protocol and https listeners serve a certificate issued by the built-in CA
+	Listeners []string
+}
+
+// GatewayRoute routes traffic from the listeners of a generated API gateway to services.
+type GatewayRoute struct {
+	// Gateway is the name of the gateway to attach to
+	Gateway string
+	// Hostnames are the hostnames the route matches, HTTP routes only
+	Hostnames []string
+	// Path is the path prefix the route matches, HTTP routes only
+	Path string
+	// Services are the services to route to, TCP routes can only have one
+	Services []string
+}
+
+// isTCP returns whether the route is a tcp-route given the protocols of every service
+func (r GatewayRoute) isTCP(protocols map[string]string) bool {
+	return protocols[r.Services[0]] == protocolTCP
+}
+
+func (c *RunnerConfig) validateAPIGateways() error {
+	gateways := make(map[string]APIGateway)
+	for i, gateway := range c.APIGateways {
+		if gateway.Name == "" {
+			return fmt.Errorf("gateway %d has no name", i+1)
+		}
+		if _, ok := gateways[gateway.Name]; ok {
+			return fmt.Errorf("duplicate gateway name specified: %q", gateway.Name)
+		}
+
+		if len(gateway.Listeners) == 0 {
+			return fmt.Errorf("gateway %q has no listeners", gateway.Name)
+		}
+		seen := make(map[string]struct{})
+		for _, listener := range gateway.Listeners {
+			switch listener {
+			case listenerHTTP, listenerHTTPS, listenerTCP:
+			default:
+				return fmt.Errorf("invalid listener %q for gateway %q, must be one of http, https or tcp", listener, gateway.Name)
+			}
+			if _, ok := seen[listener]; ok {
+				return fmt.Errorf("duplicate listener %q for gateway %q", listener, gateway.Name)
+			}
+			seen[listener] = struct{}{}
+		}
+		gateways[gateway.Name] = gateway
+	}
+
+	protocols := c.meshServiceProtocols()
+	for i, route := range c.GatewayRoutes {
+		if route.Gateway == "" {
+			return fmt.Errorf("route %d must name the gateway it attaches to", i+1)
+		}
+		gateway, ok := gateways[route.Gateway]
+		if !ok {
+			return fmt.Errorf("route %d attaches to unknown gateway %q", i+1, route.Gateway)
+		}
+
+		if len(route.Services) == 0 {
+			return fmt.Errorf("route %d on %q has no services", i+1, route.Gateway)
+		}
+		for _, service := range route.Services {
+			if _, ok := protocols[service]; !ok {
+				return fmt.Errorf("route %d on %q routes to unknown mesh service %q", i+1, route.Gateway, service)
+			}
+			if (protocols[service] == protocolTCP) != route.isTCP(protocols) {
+				return fmt.Errorf("route %d on %q cannot mix tcp and http-based services", i+1, route.Gateway)
+			}
+		}
+		if route.Path != "" && !strings.HasPrefix(route.Path, "/") {
+			return fmt.Errorf("route %d on %q has a path that doesn't start with /", i+1, route.Gateway)
+		}
+
+		if route.isTCP(protocols) {
+			if len(route.Services) > 1 {
+				return fmt.Errorf("tcp route %d on %q can only route to one service", i+1, route.Gateway)
+			}
+			if len(route.Hostnames) > 0 || route.Path != "" {
+				return fmt.Errorf("tcp route %d on %q cannot match hostnames or paths", i+1, route.Gateway)
+			}
+		}
+		if len(routeListeners(gateway, route.isTCP(protocols))) == 0 {
+			return fmt.Errorf("gateway %q has no listeners for route %d", route.Gateway, i+1)
+		}
+	}
+
+	return nil
+}
+
+// routeListeners returns the listeners of the gateway that a route attaches to
+func routeListeners(gateway APIGateway, tcp bool) []string {
+	listeners := []string{}
+	for _, listener := range gateway.Listeners {
+		if (listener == listenerTCP) == tcp {
+			listeners = append(listeners, listener)
+		}
+	}
+	return listeners
+}
+
+// certificateArgs are used to render the inline certificate of a generated gateway
+type certificateArgs struct {
+	Name string
+	SANs []string
+}
+
+// routeArgs are used to render the routes of a generated gateway
+type routeArgs struct {
+	GatewayRoute
+	Name      string
+	Listeners []string
+}
+
+// newAPIGatewayResources generates the entries for an API gateway and its routes in order,
+// the inline certificate of any https listener first, then the gateway and its routes.
+func (c *RunnerConfig) newAPIGatewayResources(server *server.Server, locality locality, gateway APIGateway) ([]interface{}, error) {
+	protocols := c.meshServiceProtocols()
+
+	routes := []routeArgs{}
+	hostnames := []string{}
+	for _, route := range c.GatewayRoutes {
+		if route.Gateway != gateway.Name {
+			continue
+		}
+		routes = append(routes, routeArgs{
+			GatewayRoute: route,
+			Name:         fmt.Sprintf("%s-route-%d", gateway.Name, len(routes)+1),
+			Listeners:    routeListeners(gateway, route.isTCP(protocols)),
+		})
+		hostnames = append(hostnames, route.Hostnames...)
+	}
+
+	resources := []interface{}{}
+	for _, listener := range gateway.Listeners {
+		if listener != listenerHTTPS {
+			continue
+		}
+		certificate, err := newGeneratedEntry(c.consulCommand, server, locality, api.InlineCertificate, gateway.Name, inlineCertificateTemplate, &certificateArgs{
+			Name: gateway.Name,
+			SANs: append(hostnames, "localhost", "127.0.0.1"),
+		})
+		if err != nil {
+			return nil, err
+		}
+		resources = append(resources, certificate)
+	}
+
+	entry, err := newGeneratedEntry(c.consulCommand, server, locality, api.APIGateway, gateway.Name, apiGatewayTemplate, gateway)
+	if err != nil {
+		return nil, err
+	}
+	resources = append(resources, &ConsulGateway{
+		ConsulConfigEntry: entry,
+		DefinitionFile:    entry.DefinitionFile,
+	})
+
+	for _, route := range routes {
+		kind, template := api.HTTPRoute, httpRouteTemplate
+		if route.isTCP(protocols) {
+			kind, template = api.TCPRoute, tcpRouteTemplate
+		}
+		entry, err := newGeneratedEntry(c.consulCommand, server, locality, kind, route.Name, template, route)
+		if err != nil {
+			return nil, err
+		}
+		resources = append(resources, entry)
+	}
+
+	return resources, nil
+}
@@ -0,0 +1,97 @@
+package authz
+
+import (
+	"context"
+	"fmt"
+	"net"
+
+	"github.com/andrewstucki/consul-services/pkg/echo"
+	"github.com/andrewstucki/consul-services/pkg/server"
+	corev3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	authv3 "github.com/envoyproxy/go-control-plane/envoy/service/auth/v3"
+	typev3 "github.com/envoyproxy/go-control-plane/envoy/type/v3"
+	"google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+)
+
+// authorizationServer implements the gRPC ext_authz protocol
+type authorizationServer struct {
+	authv3.UnimplementedAuthorizationServer
+
+	server *Server
+}
+
+func (s *Server) runGRPC(ctx context.Context) error {
+	listener, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", s.GRPCPort))
+	if err != nil {
+		return err
+	}
+
+	grpcServer := grpc.NewServer()
+	authv3.RegisterAuthorizationServer(grpcServer, &authorizationServer{server: s})
+
+	errChannel := make(chan error, 1)
+	go func() {
+		if err := grpcServer.Serve(listener); err != nil {
+			errChannel <- err
+		}
+	}()
+
+	select {
+	case <-ctx.Done():
+		grpcServer.Stop()
+		return nil
+	case err := <-errChannel:
+		return err
+	}
+}
+
+func (a *authorizationServer) Check(ctx context.Context, request *authv3.CheckRequest) (*authv3.CheckResponse, error) {
+	attributes := request.GetAttributes()
+	httpRequest := attributes.GetRequest().GetHttp()
+
+	source := ""
+	if id, err := echo.ParseSPIFFEID(attributes.GetSource().GetPrincipal()); err == nil {
+		source = id.Service
+	}
+
+	remote := ""
+	if p, ok := peer.FromContext(ctx); ok {
+		remote = p.Addr.String()
+	}
+
+	allowed, reason := a.server.check("grpc", server.AuthzRequest{
+		Method:  httpRequest.GetMethod(),
+		Path:    httpRequest.GetPath(),
+		Host:    httpRequest.GetHost(),
+		Headers: httpRequest.GetHeaders(),
+		Source:  source,
+	}, remote)
+
+	if allowed {
+		return &authv3.CheckResponse{
+			Status: &status.Status{Code: int32(codes.OK)},
+			HttpResponse: &authv3.CheckResponse_OkResponse{
+				OkResponse: &authv3.OkHttpResponse{},
+			},
+		}, nil
+	}
+
+	return &authv3.CheckResponse{
+		Status: &status.Status{
+			Code:    int32(codes.PermissionDenied),
+			Message: reason,
+		},
+		HttpResponse: &authv3.CheckResponse_DeniedResponse{
+			DeniedResponse: &authv3.DeniedHttpResponse{
+				Status: &typev3.HttpStatus{Code: typev3.StatusCode_Forbidden},
+				Headers: []*corev3.HeaderValueOption{{
+					Header: &corev3.HeaderValue{Key: "x-ext-authz", Value: "denied"},
+				}},
+				Body: reason + "\n",
+			},
+		},
+	}, nil
+}
@@ -0,0 +1,145 @@
+package authz
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/andrewstucki/consul-services/pkg/echo"
+	"github.com/andrewstucki/consul-services/pkg/server"
+	"github.com/hashicorp/go-hclog"
+	"golang.org/x/sync/errgroup"
+)
+
+// Server is an external authorization service for envoy's ext_authz filter
+// that allows or denies requests based on a policy held by the control server.
+type Server struct {
+	// Logger logs every decision made
+	Logger hclog.Logger
+	// Policy holds the rules to check requests against
+	Policy *server.AuthzPolicyStore
+	// Requests records every request checked
+	Requests *server.RequestLog
+	// HTTPPort is the port to serve the HTTP ext_authz protocol on
+	HTTPPort int
+	// GRPCPort is the port to serve the gRPC ext_authz protocol on
+	GRPCPort int
+}
+
+// Run runs both the HTTP and gRPC authorization servers until the context is canceled.
+func (s *Server) Run(ctx context.Context) error {
+	s.Logger.Info("starting ext_authz service", "http", s.HTTPPort, "grpc", s.GRPCPort)
+	defer s.Logger.Info("stopping ext_authz service")
+
+	group, ctx := errgroup.WithContext(ctx)
+	group.Go(func() error {
+		return s.runHTTP(ctx)
+	})
+	group.Go(func() error {
+		return s.runGRPC(ctx)
+	})
+	return group.Wait()
+}
+
+func (s *Server) runHTTP(ctx context.Context) error {
+	server := &http.Server{
+		Handler: http.HandlerFunc(s.checkHTTP),
+		BaseContext: func(l net.Listener) context.Context {
+			return ctx
+		},
+	}
+	defer server.Close()
+
+	listener, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", s.HTTPPort))
+	if err != nil {
+		return err
+	}
+
+	errChannel := make(chan error, 1)
+	go func() {
+		if err := server.Serve(listener); err != nil {
+			errChannel <- err
+		}
+	}()
+
+	select {
+	case <-ctx.Done():
+		return nil
+	case err := <-errChannel:
+		return err
+	}
+}
+
+// checkHTTP implements the HTTP ext_authz protocol, where envoy forwards the original
+// request and treats a 200 as allowed and anything else as the response to deny with
+func (s *Server) checkHTTP(w http.ResponseWriter, r *http.Request) {
+	headers := make(map[string]string)
+	for name := range r.Header {
+		headers[strings.ToLower(name)] = r.Header.Get(name)
+	}
+
+	source := ""
+	if uri := echo.ClientURI(r.Header.Get(echo.ClientCertificateHeader)); uri != "" {
+		if id, err := echo.ParseSPIFFEID(uri); err == nil {
+			source = id.Service
+		}
+	}
+
+	allowed, reason := s.check("http", server.AuthzRequest{
+		Method:  r.Method,
+		Path:    r.URL.RequestURI(),
+		Host:    r.Host,
+		Headers: headers,
+		Source:  source,
+	}, r.RemoteAddr)
+	if allowed {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	w.WriteHeader(http.StatusForbidden)
+	fmt.Fprintln(w, reason)
+}
+
+// check evaluates the request against the current policy, logging and recording the decision
+func (s *Server) check(protocol string, request server.AuthzRequest, remote string) (bool, string) {
+	action, rule := s.Policy.Get().Evaluate(request)
+
+	matched := "default"
+	if rule >= 0 {
+		matched = "rule " + strconv.Itoa(rule)
+	}
+	reason := "allowed by ext_authz " + matched
+	if action != server.AuthzAllow {
+		reason = "denied by ext_authz " + matched
+	}
+
+	s.Logger.Info("authorization decision",
+		"protocol", protocol,
+		"method", request.Method,
+		"host", request.Host,
+		"path", request.Path,
+		"source", request.Source,
+		"action", action,
+		"rule", matched,
+	)
+
+	headers := make(map[string][]string)
+	for name, value := range request.Headers {
+		headers[name] = []string{value}
+	}
+	s.Requests.Record(server.Request{
+		Time:    time.Now(),
+		Method:  request.Method,
+		Path:    request.Path,
+		Host:    request.Host,
+		Headers: headers,
+		Source:  remote,
+	})
+
+	return action == server.AuthzAllow, reason
+}
@@ -0,0 +1,236 @@
+package pkg
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/andrewstucki/consul-services/pkg/echo"
+	"golang.org/x/net/http2"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	// callTimeout bounds every call a service makes to its upstreams
+	callTimeout = 10 * time.Second
+	// traceParentHeader is the W3C trace context header, one is created for
+	// requests that don't already have one
+	traceParentHeader = "traceparent"
+)
+
+// traceHeaders are the tracing headers propagated from a request to the calls
+// made to upstreams
+var traceHeaders = []string{
+	"tracestate",
+	"x-request-id",
+	"x-b3-traceid",
+	"x-b3-spanid",
+	"x-b3-parentspanid",
+	"x-b3-sampled",
+	"x-b3-flags",
+	"b3",
+	"x-ot-span-context",
+	"x-cloud-trace-context",
+}
+
+// ServiceCall is an upstream a service calls when it receives a request
+type ServiceCall struct {
+	// Name is the name of the upstream
+	Name string
+	// Protocol is the protocol the upstream speaks
+	Protocol string
+	// Port is the port the sidecar listens on for the upstream
+	Port int
+}
+
+// serveCalls calls every upstream of the service, in parallel unless ?mode=sequential
+// is given, and responds with the tree of responses
+func (s *Service) serveCalls(w http.ResponseWriter, r *http.Request) {
+	traceID, headers := propagatedHeaders(r.Header)
+
+	calls := make([]*echo.Call, len(s.Calls))
+	if r.URL.Query().Get("mode") == "sequential" {
+		for i, call := range s.Calls {
+			calls[i] = s.call(r.Context(), call, r.URL.RawQuery, headers)
+		}
+	} else {
+		var wg sync.WaitGroup
+		for i, call := range s.Calls {
+			wg.Add(1)
+			go func(i int, call ServiceCall) {
+				defer wg.Done()
+				calls[i] = s.call(r.Context(), call, r.URL.RawQuery, headers)
+			}(i, call)
+		}
+		wg.Wait()
+	}
+
+	response := &echo.Call{
+		ID:      s.ID,
+		TraceID: traceID,
+		Calls:   calls,
+	}
+
+	w.Header().Set("content-type", "application/json")
+	if response.Failed() {
+		w.WriteHeader(http.StatusBadGateway)
+	}
+	json.NewEncoder(w).Encode(response)
+}
+
+// call calls the upstream, returning its response or the reason it failed
+func (s *Service) call(ctx context.Context, call ServiceCall, query string, headers http.Header) *echo.Call {
+	ctx, cancel := context.WithTimeout(ctx, callTimeout)
+	defer cancel()
+
+	start := time.Now()
+
+	var response *echo.Call
+	var err error
+	switch call.Protocol {
+	case protocolTCP:
+		response, err = callTCP(ctx, call.Port)
+	case protocolGRPC:
+		response, err = callGRPC(ctx, call.Port, headers)
+	case protocolHTTP2:
+		response, err = callHTTP(ctx, &http2.Transport{
+			AllowHTTP: true,
+			DialTLSContext: func(ctx context.Context, network, address string, _ *tls.Config) (net.Conn, error) {
+				var dialer net.Dialer
+				return dialer.DialContext(ctx, network, address)
+			},
+		}, call.Port, query, headers)
+	default:
+		response, err = callHTTP(ctx, http.DefaultTransport, call.Port, query, headers)
+	}
+	if err != nil {
+		s.Logger.Warn("error calling upstream", "upstream", call.Name, "err", err)
+		response = &echo.Call{Error: err.Error()}
+	}
+
+	response.Upstream = call.Name
+	response.Latency = time.Since(start).Round(time.Microsecond).String()
+	return response
+}
+
+func callHTTP(ctx context.Context, transport http.RoundTripper, port int, query string, headers http.Header) (*echo.Call, error) {
+	url := fmt.Sprintf("http://127.0.0.1:%d/", port)
+	if query != "" {
+		url += "?" + query
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	request.Header = headers.Clone()
+	request.Header.Set("Accept", "application/json")
+
+	response, err := (&http.Client{Transport: transport}).Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	data, err := io.ReadAll(response.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	call := decodeCall(data)
+	call.Status = response.StatusCode
+	return call, nil
+}
+
+func callGRPC(ctx context.Context, port int, headers http.Header) (*echo.Call, error) {
+	conn, err := grpc.DialContext(ctx, fmt.Sprintf("127.0.0.1:%d", port), grpc.WithTransportCredentials(insecure.NewCredentials()), grpc.WithBlock())
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	md := metadata.MD{}
+	for name, values := range headers {
+		md.Append(name, values...)
+	}
+
+	data, err := echo.CallGRPC(metadata.NewOutgoingContext(ctx, md), conn)
+	if err != nil {
+		return nil, err
+	}
+
+	call := decodeCall(data)
+	call.Status = http.StatusOK
+	return call, nil
+}
+
+func callTCP(ctx context.Context, port int) (*echo.Call, error) {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", fmt.Sprintf("127.0.0.1:%d", port))
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := conn.SetDeadline(deadline); err != nil {
+			return nil, err
+		}
+	}
+
+	data, err := io.ReadAll(conn)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return nil, io.ErrUnexpectedEOF
+	}
+	return decodeCall(data), nil
+}
+
+// decodeCall decodes the response of an upstream, which is either a tree of
+// calls, an echo response or just the id of the instance
+func decodeCall(data []byte) *echo.Call {
+	call := &echo.Call{}
+	if err := json.Unmarshal(data, call); err == nil && call.ID != "" {
+		return call
+	}
+	return &echo.Call{ID: strings.TrimSpace(string(data))}
+}
+
+// propagatedHeaders returns the tracing headers to send to upstreams along
+// with the id of the trace, continuing the trace of the request if it has one
+func propagatedHeaders(requestHeaders http.Header) (string, http.Header) {
+	headers := make(http.Header)
+	for _, name := range traceHeaders {
+		if values := requestHeaders.Values(name); len(values) > 0 {
+			headers[http.CanonicalHeaderKey(name)] = values
+		}
+	}
+
+	// traceparent is version-traceid-parentid-flags
+	traceID, flags := randomHex(16), "01"
+	if parts := strings.Split(requestHeaders.Get(traceParentHeader), "-"); len(parts) == 4 {
+		traceID, flags = parts[1], parts[3]
+	}
+	headers.Set(traceParentHeader, fmt.Sprintf("00-%s-%s-%s", traceID, randomHex(8), flags))
+
+	return traceID, headers
+}
+
+func randomHex(size int) string {
+	data := make([]byte, size)
+	rand.Read(data)
+	return hex.EncodeToString(data)
+}
@@ -0,0 +1,125 @@
+package pkg
+
+import (
+	"fmt"
+	"time"
+)
+
+// certificateRequest builds up a certificate with non-default properties from within
+// a template, i.e.
+//
+//	{{ $request := .NewCertificate "example.consul.local" }}
+//	{{ $request = $request.ECDSA 384 }}
+//	{{ $request = $request.Intermediates 2 }}
+//	{{ $certificate := $request.Issue }}
+type certificateRequest struct {
+	tracker       *tracker
+	options       generateCertificateOptions
+	intermediates int
+	// dryRun skips actually generating anything when templates are only
+	// being parsed for their metadata
+	dryRun bool
+	err    error
+}
+
+// NewCertificate starts building a certificate for the given name and SANs.
+func (t *tracker) NewCertificate(name string, sans ...string) *certificateRequest {
+	return &certificateRequest{
+		tracker: t,
+		options: generateCertificateOptions{
+			Name:      name,
+			ExtraSANs: sans,
+		},
+	}
+}
+
+// RSA uses an RSA key of the given size.
+func (r *certificateRequest) RSA(bits int) *certificateRequest {
+	r.options.KeyType = keyTypeRSA
+	r.options.Bits = bits
+	return r
+}
+
+// ECDSA uses an ECDSA key of the given curve size, one of 256, 384 or 521.
+func (r *certificateRequest) ECDSA(bits int) *certificateRequest {
+	r.options.KeyType = keyTypeECDSA
+	r.options.Bits = bits
+	return r
+}
+
+// NotBefore sets when the certificate becomes valid relative to now, i.e. "-1h" or "30m".
+func (r *certificateRequest) NotBefore(offset string) *certificateRequest {
+	duration, err := time.ParseDuration(offset)
+	if err != nil {
+		r.err = fmt.Errorf("invalid NotBefore offset: %w", err)
+		return r
+	}
+	r.options.NotBefore = time.Now().Add(duration)
+	return r
+}
+
+// NotAfter sets when the certificate expires relative to now, i.e. "24h" or "-1h".
+func (r *certificateRequest) NotAfter(offset string) *certificateRequest {
+	duration, err := time.ParseDuration(offset)
+	if err != nil {
+		r.err = fmt.Errorf("invalid NotAfter offset: %w", err)
+		return r
+	}
+	r.options.NotAfter = time.Now().Add(duration)
+	return r
+}
+
+// Expired makes the certificate one that expired an hour ago.
+func (r *certificateRequest) Expired() *certificateRequest {
+	return r.NotBefore("-48h").NotAfter("-1h")
+}
+
+// NotYetValid makes the certificate one that only becomes valid in an hour.
+func (r *certificateRequest) NotYetValid() *certificateRequest {
+	return r.NotBefore("1h").NotAfter("48h")
+}
+
+// Intermediates issues the certificate through a chain of the given number of
+// intermediate CAs rather than directly from the root.
+func (r *certificateRequest) Intermediates(count int) *certificateRequest {
+	r.intermediates = count
+	return r
+}
+
+// Issue generates the certificate.
+func (r *certificateRequest) Issue() (*CertificateInfo, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	if r.dryRun {
+		return &CertificateInfo{}, nil
+	}
+
+	issuer, err := CertificateAuthority()
+	if err != nil {
+		return nil, err
+	}
+
+	for i := 1; i <= r.intermediates; i++ {
+		issuer, err = generateSignedCertificate(generateCertificateOptions{
+			CA:      issuer,
+			IsCA:    true,
+			Name:    fmt.Sprintf("Intermediate CA %d", i),
+			KeyType: r.options.KeyType,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	options := r.options
+	options.CA = issuer
+	certificate, err := generateSignedCertificate(options)
+	if err != nil {
+		return nil, err
+	}
+
+	r.tracker.certificates = append(r.tracker.certificates, certificate)
+
+	return certificate, nil
+}
@@ -1,38 +1,171 @@
 package pkg
 
 import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/rsa"
+	"crypto/sha1"
 	"crypto/x509"
 	"crypto/x509/pkix"
 	"encoding/pem"
+	"errors"
+	"fmt"
 	"math/big"
 	"net"
+	"os"
+	"path"
+	"sync"
 	"time"
 )
 
+const (
+	caCertificateFile = "ca.pem"
+	caPrivateKeyFile  = "ca-key.pem"
+
+	keyTypeRSA   = "rsa"
+	keyTypeECDSA = "ecdsa"
+)
+
 var (
-	CA *CertificateInfo
+	ca      *CertificateInfo
+	caMutex sync.Mutex
 )
 
-func init() {
+// CertificateAuthority returns the root CA used for issuing certificates,
+// generating one the first time it's needed.
+func CertificateAuthority() (*CertificateInfo, error) {
+	caMutex.Lock()
+	defer caMutex.Unlock()
+
+	if ca != nil {
+		return ca, nil
+	}
+
 	rootCA, err := generateSignedCertificate(generateCertificateOptions{
 		IsCA: true,
 		Name: "RootCA",
 	})
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
-	CA = rootCA
+	ca = rootCA
+	return ca, nil
+}
+
+// LoadCertificateAuthority loads the root CA from the given directory so that it is
+// stable across runs, generating and persisting a new one there if none exists.
+func LoadCertificateAuthority(directory string) (*CertificateInfo, error) {
+	certificateFile := path.Join(directory, caCertificateFile)
+	privateKeyFile := path.Join(directory, caPrivateKeyFile)
+
+	certificatePEM, err := os.ReadFile(certificateFile)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	if os.IsNotExist(err) {
+		rootCA, err := CertificateAuthority()
+		if err != nil {
+			return nil, err
+		}
+		if err := os.MkdirAll(directory, 0700); err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(certificateFile, []byte(rootCA.Certificate), 0600); err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(privateKeyFile, []byte(rootCA.PrivateKey), 0600); err != nil {
+			return nil, err
+		}
+		return rootCA, nil
+	}
+
+	privateKeyPEM, err := os.ReadFile(privateKeyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	rootCA, err := parseCertificate(certificatePEM, privateKeyPEM)
+	if err != nil {
+		return nil, err
+	}
+	if !rootCA.cert.IsCA {
+		return nil, errors.New("persisted certificate is not a CA")
+	}
+
+	caMutex.Lock()
+	defer caMutex.Unlock()
+
+	ca = rootCA
+	return ca, nil
+}
+
+func parseCertificate(certificatePEM, privateKeyPEM []byte) (*CertificateInfo, error) {
+	certificateBlock, _ := pem.Decode(certificatePEM)
+	if certificateBlock == nil {
+		return nil, errors.New("invalid certificate PEM")
+	}
+	cert, err := x509.ParseCertificate(certificateBlock.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	privateKeyBlock, _ := pem.Decode(privateKeyPEM)
+	if privateKeyBlock == nil {
+		return nil, errors.New("invalid private key PEM")
+	}
+	privateKey, err := parsePrivateKey(privateKeyBlock)
+	if err != nil {
+		return nil, err
+	}
+
+	return &CertificateInfo{
+		Certificate:  string(certificatePEM),
+		PrivateKey:   string(privateKeyPEM),
+		Chain:        string(certificatePEM),
+		SerialNumber: fmt.Sprintf("%x", cert.SerialNumber),
+		cert:         cert,
+		privateKey:   privateKey,
+	}, nil
+}
+
+func parsePrivateKey(block *pem.Block) (crypto.Signer, error) {
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		return x509.ParseECPrivateKey(block.Bytes)
+	}
+
+	privateKey, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	signer, ok := privateKey.(crypto.Signer)
+	if !ok {
+		return nil, errors.New("unsupported private key type")
+	}
+	return signer, nil
 }
 
 // CertificateInfo wraps all of the information needed to describe a generated
 // certificate
 type CertificateInfo struct {
+	// Certificate is the PEM encoded certificate
 	Certificate string
-	PrivateKey  string
-	privateKey  *rsa.PrivateKey
-	cert        *x509.Certificate
+	// PrivateKey is the PEM encoded private key of the certificate
+	PrivateKey string
+	// Intermediates are the PEM encoded intermediate CAs that issued the certificate, if any
+	Intermediates string
+	// Chain is the certificate followed by any intermediates, suitable for serving
+	Chain string
+	// SerialNumber is the hex encoded serial number of the certificate
+	SerialNumber string
+	privateKey   crypto.Signer
+	cert         *x509.Certificate
 }
 
 type generateCertificateOptions struct {
@@ -40,29 +173,93 @@ type generateCertificateOptions struct {
 	IsCA      bool
 	Name      string
 	ExtraSANs []string
-	Bits      int
+	// KeyType is the type of key to generate, either rsa or ecdsa, defaults to rsa
+	KeyType string
+	// Bits is the size of the key, defaults to 2048 for rsa and 256 for ecdsa
+	Bits int
+	// NotBefore is when the certificate becomes valid, defaults to 10 minutes ago
+	NotBefore time.Time
+	// NotAfter is when the certificate expires, defaults to 10 years from now
+	NotAfter time.Time
 }
 
 func generateCertificate(name string, sans ...string) (*CertificateInfo, error) {
+	rootCA, err := CertificateAuthority()
+	if err != nil {
+		return nil, err
+	}
+
 	return generateSignedCertificate(generateCertificateOptions{
-		CA:        CA,
+		CA:        rootCA,
 		Name:      name,
 		ExtraSANs: sans,
 	})
 }
 
-func generateSignedCertificate(options generateCertificateOptions) (*CertificateInfo, error) {
-	bits := options.Bits
-	if bits == 0 {
-		bits = 2048
+func generatePrivateKey(keyType string, bits int) (crypto.Signer, []byte, error) {
+	switch keyType {
+	case "", keyTypeRSA:
+		if bits == 0 {
+			bits = 2048
+		}
+		privateKey, err := rsa.GenerateKey(rand.Reader, bits)
+		if err != nil {
+			return nil, nil, err
+		}
+		return privateKey, pem.EncodeToMemory(&pem.Block{
+			Type:  "RSA PRIVATE KEY",
+			Bytes: x509.MarshalPKCS1PrivateKey(privateKey),
+		}), nil
+	case keyTypeECDSA:
+		var curve elliptic.Curve
+		switch bits {
+		case 0, 256:
+			curve = elliptic.P256()
+		case 384:
+			curve = elliptic.P384()
+		case 521:
+			curve = elliptic.P521()
+		default:
+			return nil, nil, fmt.Errorf("unsupported ecdsa key size %d", bits)
+		}
+		privateKey, err := ecdsa.GenerateKey(curve, rand.Reader)
+		if err != nil {
+			return nil, nil, err
+		}
+		data, err := x509.MarshalECPrivateKey(privateKey)
+		if err != nil {
+			return nil, nil, err
+		}
+		return privateKey, pem.EncodeToMemory(&pem.Block{
+			Type:  "EC PRIVATE KEY",
+			Bytes: data,
+		}), nil
+	default:
+		return nil, nil, fmt.Errorf("unsupported key type %q", keyType)
+	}
+}
+
+func generateSerialNumber() (*big.Int, error) {
+	return rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+}
+
+func subjectKeyID(publicKey crypto.PublicKey) ([]byte, error) {
+	data, err := x509.MarshalPKIXPublicKey(publicKey)
+	if err != nil {
+		return nil, err
 	}
-	privateKey, err := rsa.GenerateKey(rand.Reader, bits)
+	hash := sha1.Sum(data)
+	return hash[:], nil
+}
+
+func generateSignedCertificate(options generateCertificateOptions) (*CertificateInfo, error) {
+	privateKey, privateKeyPEM, err := generatePrivateKey(options.KeyType, options.Bits)
 	if err != nil {
 		return nil, err
 	}
 	usage := x509.KeyUsageDigitalSignature
 	if options.IsCA {
-		usage = x509.KeyUsageCertSign
+		usage = x509.KeyUsageCertSign | x509.KeyUsageCRLSign | x509.KeyUsageDigitalSignature
 	}
 
 	sans := []string{}
@@ -77,9 +274,26 @@ func generateSignedCertificate(options generateCertificateOptions) (*Certificate
 		}
 	}
 
-	expiration := time.Now().AddDate(10, 0, 0)
+	notBefore := options.NotBefore
+	if notBefore.IsZero() {
+		notBefore = time.Now().Add(-10 * time.Minute)
+	}
+	notAfter := options.NotAfter
+	if notAfter.IsZero() {
+		notAfter = time.Now().AddDate(10, 0, 0)
+	}
+
+	serialNumber, err := generateSerialNumber()
+	if err != nil {
+		return nil, err
+	}
+	keyID, err := subjectKeyID(privateKey.Public())
+	if err != nil {
+		return nil, err
+	}
+
 	cert := &x509.Certificate{
-		SerialNumber: big.NewInt(1),
+		SerialNumber: serialNumber,
 		DNSNames:     sans,
 		Subject: pkix.Name{
 			Organization:  []string{"Testing, INC."},
@@ -92,9 +306,9 @@ func generateSignedCertificate(options generateCertificateOptions) (*Certificate
 		},
 		IsCA:                  options.IsCA,
 		IPAddresses:           ips,
-		NotBefore:             time.Now().Add(-10 * time.Minute),
-		NotAfter:              expiration,
-		SubjectKeyId:          []byte{1, 2, 3, 4, 6},
+		NotBefore:             notBefore,
+		NotAfter:              notAfter,
+		SubjectKeyId:          keyID,
 		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth, x509.ExtKeyUsageServerAuth},
 		KeyUsage:              usage,
 		BasicConstraintsValid: true,
@@ -107,7 +321,11 @@ func generateSignedCertificate(options generateCertificateOptions) (*Certificate
 	if options.CA != nil {
 		caPrivateKey = options.CA.privateKey
 	}
-	data, err := x509.CreateCertificate(rand.Reader, cert, caCert, &privateKey.PublicKey, caPrivateKey)
+	data, err := x509.CreateCertificate(rand.Reader, cert, caCert, privateKey.Public(), caPrivateKey)
+	if err != nil {
+		return nil, err
+	}
+	parsed, err := x509.ParseCertificate(data)
 	if err != nil {
 		return nil, err
 	}
@@ -116,15 +334,24 @@ func generateSignedCertificate(options generateCertificateOptions) (*Certificate
 		Type:  "CERTIFICATE",
 		Bytes: data,
 	})
-	privateKeyPEM := pem.EncodeToMemory(&pem.Block{
-		Type:  "RSA PRIVATE KEY",
-		Bytes: x509.MarshalPKCS1PrivateKey(privateKey),
-	})
+
+	// carry along the chain of anything that isn't the root
+	intermediates := ""
+	if options.CA != nil && !isRoot(options.CA.cert) {
+		intermediates = options.CA.Certificate + options.CA.Intermediates
+	}
 
 	return &CertificateInfo{
-		Certificate: string(certificatePEM),
-		PrivateKey:  string(privateKeyPEM),
-		cert:        cert,
-		privateKey:  privateKey,
+		Certificate:   string(certificatePEM),
+		PrivateKey:    string(privateKeyPEM),
+		Intermediates: intermediates,
+		Chain:         string(certificatePEM) + intermediates,
+		SerialNumber:  fmt.Sprintf("%x", serialNumber),
+		cert:          parsed,
+		privateKey:    privateKey,
 	}, nil
 }
+
+func isRoot(cert *x509.Certificate) bool {
+	return bytes.Equal(cert.RawIssuer, cert.RawSubject)
+}
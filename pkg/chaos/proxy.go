@@ -0,0 +1,147 @@
+package chaos
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/andrewstucki/consul-services/pkg/server"
+	"github.com/hashicorp/go-hclog"
+)
+
+// chunkSize is the most data forwarded at once, keeping it small lets
+// latency and bandwidth limits apply smoothly
+const chunkSize = 4096
+
+// Proxy is a TCP proxy that injects network faults between a sidecar and the
+// service it fronts, its settings are read on every chunk so they can be
+// changed while connections are open.
+type Proxy struct {
+	// Logger logs injected faults
+	Logger hclog.Logger
+	// Settings holds the faults to inject
+	Settings *server.ChaosStore
+	// Port is the port to listen on
+	Port int
+	// Target is the port of the service to forward to
+	Target int
+}
+
+// Run proxies connections until the context is canceled.
+func (p *Proxy) Run(ctx context.Context) error {
+	listener, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", p.Port))
+	if err != nil {
+		return err
+	}
+
+	p.Logger.Info("starting chaos proxy", "port", p.Port, "target", p.Target)
+	defer p.Logger.Info("stopping chaos proxy")
+
+	go func() {
+		<-ctx.Done()
+		listener.Close()
+	}()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			select {
+			case <-ctx.Done():
+				return nil
+			default:
+				return err
+			}
+		}
+		go p.handle(ctx, conn.(*net.TCPConn))
+	}
+}
+
+func (p *Proxy) handle(ctx context.Context, downstream *net.TCPConn) {
+	settings := p.Settings.Get()
+	source := downstream.RemoteAddr().String()
+
+	if settings.DropRate > 0 && rand.Float64() < settings.DropRate {
+		p.Logger.Info("dropping connection", "source", source)
+		// a zero linger makes the close send a reset rather than a FIN
+		downstream.SetLinger(0)
+		downstream.Close()
+		return
+	}
+
+	if settings.Blackhole {
+		p.Logger.Info("blackholing connection", "source", source)
+		go func() {
+			<-ctx.Done()
+			downstream.Close()
+		}()
+		io.Copy(io.Discard, downstream)
+		downstream.Close()
+		return
+	}
+
+	upstream, err := net.Dial("tcp", fmt.Sprintf("127.0.0.1:%d", p.Target))
+	if err != nil {
+		p.Logger.Error("unable to connect to service", "source", source, "err", err)
+		downstream.Close()
+		return
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		p.forward(upstream, downstream)
+		upstream.(*net.TCPConn).CloseWrite()
+	}()
+	go func() {
+		defer wg.Done()
+		p.forward(downstream, upstream)
+
+		if slowClose := p.Settings.Get().SlowClose; slowClose > 0 {
+			p.Logger.Info("delaying close", "source", source, "delay", slowClose)
+			select {
+			case <-time.After(slowClose):
+			case <-ctx.Done():
+			}
+		}
+		downstream.CloseWrite()
+	}()
+	wg.Wait()
+
+	upstream.Close()
+	downstream.Close()
+}
+
+// forward copies from src to dst a chunk at a time, applying the latency and
+// bandwidth settings as they are when each chunk is read
+func (p *Proxy) forward(dst io.Writer, src io.Reader) {
+	buffer := make([]byte, chunkSize)
+	for {
+		read, err := src.Read(buffer)
+		if read > 0 {
+			settings := p.Settings.Get()
+
+			delay := settings.Latency
+			if settings.Jitter > 0 {
+				delay += time.Duration(rand.Int63n(int64(settings.Jitter)))
+			}
+			if settings.Bandwidth > 0 {
+				delay += time.Duration(read) * time.Second / time.Duration(settings.Bandwidth*1024)
+			}
+			if delay > 0 {
+				time.Sleep(delay)
+			}
+
+			if _, err := dst.Write(buffer[:read]); err != nil {
+				return
+			}
+		}
+		if err != nil {
+			return
+		}
+	}
+}
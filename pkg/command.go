@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"errors"
+	"fmt"
 	"io"
 	"io/fs"
 	"os"
@@ -13,6 +14,7 @@ import (
 	"runtime"
 	"sync"
 
+	"github.com/andrewstucki/consul-services/pkg/server"
 	"github.com/andrewstucki/consul-services/pkg/vfs"
 	"github.com/hashicorp/go-hclog"
 )
@@ -21,16 +23,29 @@ import (
 type ConsulCommand struct {
 	// ConsulBinary is the path on the system to the Consul binary used to invoke registration and connect commands.
 	ConsulBinary string
+	// DataplaneBinary is the path on the system to the consul-dataplane binary used for dataplane sidecars, if any.
+	DataplaneBinary string
 	// LogFolder is the temporary folder to use in rendering out log files
 	LogFolder string
 	// Logger is the logger used for logging messages
 	Logger hclog.Logger
 
 	folder    string
-	processes []*exec.Cmd
+	processes []*process
 	mutex     sync.Mutex
 }
 
+// process is a running invocation of the Consul or consul-dataplane binary
+type process struct {
+	cmd *exec.Cmd
+	// log is the file the process writes its output to
+	log string
+	// killed is set when the process was killed on purpose
+	killed bool
+	// exited is set once the process has been waited on
+	exited bool
+}
+
 func newCommand(binary string, logger hclog.Logger) (*ConsulCommand, error) {
 	consul, err := findConsul(binary)
 	if err != nil {
@@ -64,15 +79,38 @@ func newCommand(binary string, logger hclog.Logger) (*ConsulCommand, error) {
 // Cleanup cleans up system resources after we're done
 func (c *ConsulCommand) Cleanup() {
 	c.mutex.Lock()
-	for _, cmd := range c.processes {
-		cmd.Cancel()
+	for _, process := range c.processes {
+		process.cmd.Cancel()
 	}
 	c.mutex.Unlock()
 	os.RemoveAll(c.folder)
 }
 
+func (c *ConsulCommand) createLogFile() (*os.File, error) {
+	return os.CreateTemp(c.LogFolder, "process-*.log")
+}
+
+// createServiceLogger creates a log file for an in-process service and
+// a logger that writes to it.
+func (c *ConsulCommand) createServiceLogger(name string) (hclog.Logger, *os.File, error) {
+	output, err := c.createLogFile()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return hclog.New(&hclog.LoggerOptions{
+		Name:   name,
+		Level:  hclog.Debug,
+		Output: output,
+	}), output, nil
+}
+
 func (c *ConsulCommand) runConsulBinary(ctx context.Context, logFn func(log string), args []string) error {
-	output, err := os.CreateTemp(c.LogFolder, "process-*.log")
+	return c.runBinary(ctx, c.ConsulBinary, logFn, args)
+}
+
+func (c *ConsulCommand) runBinary(ctx context.Context, binary string, logFn func(log string), args []string) error {
+	output, err := c.createLogFile()
 	if err != nil {
 		return err
 	}
@@ -85,22 +123,31 @@ func (c *ConsulCommand) runConsulBinary(ctx context.Context, logFn func(log stri
 	var errBuffer bytes.Buffer
 	writer := io.MultiWriter(&errBuffer, output)
 
-	cmd := exec.CommandContext(ctx, c.ConsulBinary, args...)
+	cmd := exec.CommandContext(ctx, binary, args...)
 	cmd.Stderr = writer
 	cmd.Stdout = output
 
 	// cmd.Stderr = os.Stderr
 	// cmd.Stdout = os.Stdout
 
-	c.mutex.Lock()
-	c.processes = append(c.processes, cmd)
-	c.mutex.Unlock()
+	process := &process{cmd: cmd, log: output.Name()}
 
+	c.mutex.Lock()
 	if err := cmd.Start(); err != nil {
+		c.mutex.Unlock()
 		return err
 	}
+	c.processes = append(c.processes, process)
+	c.mutex.Unlock()
 
-	if err := cmd.Wait(); err != nil {
+	err = cmd.Wait()
+	killed := c.markExited(process)
+	if err != nil {
+		if killed {
+			// we killed it deliberately, so don't take everything else down with it
+			c.Logger.Info("process killed", "log", process.log)
+			return nil
+		}
 		if _, ok := err.(*exec.ExitError); ok {
 			return errors.New(errBuffer.String())
 		}
@@ -110,9 +157,46 @@ func (c *ConsulCommand) runConsulBinary(ctx context.Context, logFn func(log stri
 	return nil
 }
 
+// Signal kills, pauses or resumes the running process writing to the given log file.
+func (c *ConsulCommand) Signal(log, action string) error {
+	signal, err := processSignal(action)
+	if err != nil {
+		return err
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for _, process := range c.processes {
+		if process.log != log || process.exited {
+			continue
+		}
+		if action == server.ActionKill {
+			process.killed = true
+		}
+		c.Logger.Info("signaling process", "action", action, "log", log)
+		return process.cmd.Process.Signal(signal)
+	}
+
+	return errors.New("no running process found")
+}
+
+// markExited records that the process has exited and returns whether it was killed on purpose
+func (c *ConsulCommand) markExited(process *process) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	process.exited = true
+	return process.killed
+}
+
 func findConsul(binary string) (string, error) {
-	paths := []string{binary, defaultBinaryPath}
-	path, err := exec.LookPath(binaryName)
+	return findBinary(binary, defaultBinaryPath, binaryName)
+}
+
+func findBinary(binary, defaultPath, name string) (string, error) {
+	paths := []string{binary, defaultPath}
+	path, err := exec.LookPath(name)
 	if err != nil && !os.IsNotExist(err) {
 		return "", err
 	}
@@ -133,7 +217,7 @@ func findConsul(binary string) (string, error) {
 			return normalized, nil
 		}
 	}
-	return "", errors.New("consul binary not found")
+	return "", fmt.Errorf("%s binary not found", name)
 }
 
 func checkConsulExecutable(path string) (bool, string, error) {
@@ -15,3 +15,15 @@ func GatewayRegistrationArgs(kind, name, address string, adminPort, registration
 		"--", "-l", "trace",
 	}
 }
+
+// WithExposedServers adds the flag that exposes the Consul servers of the local datacenter
+// through a mesh gateway, for federating datacenters through it, to the registration args
+// of a mesh gateway
+func WithExposedServers(args []string) []string {
+	for i, arg := range args {
+		if arg == "--" {
+			return append(append(append([]string{}, args[:i]...), "-expose-servers"), args[i:]...)
+		}
+	}
+	return append(args, "-expose-servers")
+}
@@ -2,6 +2,8 @@ package commands
 
 import (
 	"fmt"
+	"strconv"
+	"strings"
 )
 
 func RegisterServiceArgs(datacenter, address, file string) []string {
@@ -22,3 +24,29 @@ func SidecarArgs(address, id string, adminPort int) []string {
 		"--", "-l", "trace",
 	}
 }
+
+func BuiltinSidecarArgs(address, id string) []string {
+	return []string{
+		"connect", "proxy",
+		"-http-addr", address,
+		"-sidecar-for", id,
+	}
+}
+
+func DataplaneArgs(grpcAddress string, grpcPort int, node, proxyID string, adminPort, gracefulPort int) []string {
+	return []string{
+		"-addresses", grpcAddress,
+		"-grpc-port", strconv.Itoa(grpcPort),
+		"-tls-disabled",
+		"-service-node-name", node,
+		"-proxy-service-id", proxyID,
+		"-envoy-admin-bind-address", "127.0.0.1",
+		"-envoy-admin-bind-port", strconv.Itoa(adminPort),
+		"-graceful-port", strconv.Itoa(gracefulPort),
+		"-log-level", "trace",
+	}
+}
+
+func DataplaneCommand(args []string) string {
+	return fmt.Sprintf("consul-dataplane %s", strings.Join(args, " "))
+}
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
 
 	"github.com/hashicorp/go-hclog"
 )
@@ -11,8 +12,44 @@ import (
 const (
 	defaultBinaryPath = "consul"
 	binaryName        = "consul"
+
+	defaultDataplaneBinaryPath = "consul-dataplane"
+	dataplaneBinaryName        = "consul-dataplane"
 )
 
+// InstanceGroup assigns tags, metadata and a response body to a subset of the
+// duplicates of each service, giving service-resolver subsets something to select on.
+type InstanceGroup struct {
+	// Name identifies the group in responses
+	Name string
+	// Tags are added to the service registration
+	Tags []string
+	// Meta is added to the service registration
+	Meta map[string]string
+	// Body replaces the plain-text response of HTTP services, which is the instance id by default
+	Body string
+}
+
+// VersionGroups returns instance groups tagged and with the metadata version=<version>
+// for each of the given versions, ignoring repeats.
+func VersionGroups(versions []string) []InstanceGroup {
+	groups := []InstanceGroup{}
+	seen := map[string]struct{}{}
+	for _, version := range versions {
+		if _, ok := seen[version]; ok {
+			continue
+		}
+		seen[version] = struct{}{}
+
+		groups = append(groups, InstanceGroup{
+			Name: version,
+			Tags: []string{version},
+			Meta: map[string]string{"version": version},
+		})
+	}
+	return groups
+}
+
 // RunnerConfig configures a service runner
 type RunnerConfig struct {
 	// TCPServiceCount specifies the number of TCP-based services to register on the mesh.
@@ -23,6 +60,39 @@ type RunnerConfig struct {
 	ExternalTCPServiceCount int
 	// ExternalHTTPServiceCount specifies the number of external HTTP-based services to register on the mesh.
 	ExternalHTTPServiceCount int
+	// HTTP2ServiceCount specifies the number of HTTP/2-based services to register on the mesh.
+	HTTP2ServiceCount int
+	// GRPCServiceCount specifies the number of gRPC-based services to register on the mesh.
+	GRPCServiceCount int
+	// ExternalHTTP2ServiceCount specifies the number of external HTTP/2-based services to register on the mesh.
+	ExternalHTTP2ServiceCount int
+	// ExternalGRPCServiceCount specifies the number of external gRPC-based services to register on the mesh.
+	ExternalGRPCServiceCount int
+	// TerminatingGateway specifies whether to run a terminating gateway in each datacenter
+	// linking the external services registered there.
+	TerminatingGateway bool
+	// TerminatingGatewayTLS specifies whether external services serve TLS with certificates
+	// from the built-in CA, which the terminating gateway verifies.
+	TerminatingGatewayTLS bool
+	// APIGateways specifies API gateways to generate in each datacenter.
+	APIGateways []APIGateway
+	// GatewayRoutes specifies the routes to generate on the API gateways.
+	GatewayRoutes []GatewayRoute
+	// MeshGatewayMode specifies the mesh gateway mode, one of local, remote or none, written
+	// to the proxy-defaults of multi-datacenter runs unless a resource file supplies them,
+	// defaults to local.
+	MeshGatewayMode string
+	// WANFederation specifies whether multiple datacenters federate through their mesh
+	// gateways rather than by joining their agents over the WAN directly.
+	WANFederation bool
+	// NativeServiceCount specifies the number of connect-native HTTP-based services to register on the mesh.
+	NativeServiceCount int
+	// Calls maps the names of services to the upstreams they call when they
+	// receive a request, building call chains through the mesh
+	Calls map[string][]string
+	// Sidecars maps the names of mesh services to the sidecar run for them, one of
+	// envoy, builtin or dataplane, services not in the map run envoy
+	Sidecars map[string]string
 	// ServiceDuplicates is the amount of times a service should be duplicated (i.e. have the same
 	// service name, but different ids)
 	ServiceDuplicates int
@@ -30,12 +100,27 @@ type RunnerConfig struct {
 	ResourceFolder string
 	// ConsulBinary specifies the Consul binary to use for running services.
 	ConsulBinary string
+	// DataplaneBinary specifies the consul-dataplane binary to use for dataplane sidecars.
+	DataplaneBinary string
 	// Socket specifies the unix socket that the control server serves traffic on.
 	Socket string
 	// RunConsul specifies whether a Consul agent in dev mode should also be run
 	RunConsul bool
 	// Datacenters specifies the list of datacenters to deploy resources in.
 	Datacenters []string
+	// InstanceGroups specifies groups that duplicate services are assigned to round-robin.
+	InstanceGroups []InstanceGroup
+	// Region specifies the region that agents and services are registered in for locality-aware routing.
+	Region string
+	// Zones specifies the zones within Region that duplicate services are spread across round-robin.
+	Zones []string
+	// CertificateAuthorityDirectory specifies a directory to load a persisted root CA
+	// from, if no CA exists there, one is generated and written to it.
+	CertificateAuthorityDirectory string
+	// Chaos specifies whether to run a chaos proxy between each service and its sidecar.
+	Chaos bool
+	// ExtAuthz specifies whether to run the built-in ext_authz service on the mesh.
+	ExtAuthz bool
 	// Logger specifies the logger to use for output
 	Logger hclog.Logger
 
@@ -63,11 +148,43 @@ func (c *RunnerConfig) Validate() error {
 		return err
 	}
 
+	if err := c.validateMeshGateways(); err != nil {
+		return err
+	}
+
 	if err := c.validateResourceFolder(); err != nil {
 		return err
 	}
 
-	return c.validateServiceCounts()
+	if err := c.validateCertificateAuthority(); err != nil {
+		return err
+	}
+
+	if err := c.validateLocality(); err != nil {
+		return err
+	}
+
+	if err := c.validateInstanceGroups(); err != nil {
+		return err
+	}
+
+	if err := c.validateServiceCounts(); err != nil {
+		return err
+	}
+
+	if err := c.validateTerminatingGateway(); err != nil {
+		return err
+	}
+
+	if err := c.validateAPIGateways(); err != nil {
+		return err
+	}
+
+	if err := c.validateCalls(); err != nil {
+		return err
+	}
+
+	return c.validateSidecars()
 }
 
 func (c *RunnerConfig) validateConsul() error {
@@ -146,8 +263,106 @@ func (c *RunnerConfig) validateResourceFolder() error {
 	return nil
 }
 
+func (c *RunnerConfig) validateCertificateAuthority() error {
+	if c.CertificateAuthorityDirectory == "" {
+		return nil
+	}
+
+	info, err := os.Stat(c.CertificateAuthorityDirectory)
+	if os.IsNotExist(err) {
+		// the CA is generated into the directory when the runner starts, so
+		// just make sure that it can be created
+		parent, err := os.Stat(filepath.Dir(c.CertificateAuthorityDirectory))
+		if err != nil {
+			return err
+		}
+		if !parent.IsDir() {
+			return fmt.Errorf("%q is not a directory", filepath.Dir(c.CertificateAuthorityDirectory))
+		}
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("CA directory %q is not a directory", c.CertificateAuthorityDirectory)
+	}
+
+	// a persisted CA needs both its certificate and its private key
+	_, certificateErr := os.Stat(filepath.Join(c.CertificateAuthorityDirectory, caCertificateFile))
+	_, privateKeyErr := os.Stat(filepath.Join(c.CertificateAuthorityDirectory, caPrivateKeyFile))
+	if os.IsNotExist(certificateErr) != os.IsNotExist(privateKeyErr) {
+		return fmt.Errorf("CA directory %q must contain both %s and %s", c.CertificateAuthorityDirectory, caCertificateFile, caPrivateKeyFile)
+	}
+	return nil
+}
+
+func (c *RunnerConfig) validateLocality() error {
+	if len(c.Zones) > 0 && c.Region == "" {
+		return errors.New("a region must be specified when specifying zones")
+	}
+	return nil
+}
+
+// zoneFor returns the locality of the given duplicate of a service
+func (c *RunnerConfig) zoneFor(duplicate int) serviceLocality {
+	locality := serviceLocality{Region: c.Region}
+	if len(c.Zones) > 0 {
+		locality.Zone = c.Zones[(duplicate-1)%len(c.Zones)]
+	}
+	return locality
+}
+
+func (c *RunnerConfig) validateInstanceGroups() error {
+	seen := map[string]struct{}{}
+	for _, group := range c.InstanceGroups {
+		if group.Name == "" {
+			return errors.New("instance groups must have a name")
+		}
+		if _, ok := seen[group.Name]; ok {
+			return fmt.Errorf("duplicate instance group name specified: %q", group.Name)
+		}
+		seen[group.Name] = struct{}{}
+	}
+	return nil
+}
+
+// groupFor returns the instance group of the given duplicate of a service, if any
+func (c *RunnerConfig) groupFor(duplicate int) *InstanceGroup {
+	if len(c.InstanceGroups) == 0 {
+		return nil
+	}
+	return &c.InstanceGroups[(duplicate-1)%len(c.InstanceGroups)]
+}
+
+// protocolCount is the number of services to run for a protocol
+type protocolCount struct {
+	protocol string
+	count    int
+}
+
+// meshServiceCounts returns the number of mesh services to run for each protocol
+func (c *RunnerConfig) meshServiceCounts() []protocolCount {
+	return []protocolCount{
+		{protocol: protocolHTTP, count: c.HTTPServiceCount},
+		{protocol: protocolTCP, count: c.TCPServiceCount},
+		{protocol: protocolHTTP2, count: c.HTTP2ServiceCount},
+		{protocol: protocolGRPC, count: c.GRPCServiceCount},
+	}
+}
+
+// externalServiceCounts returns the number of external services to run for each protocol
+func (c *RunnerConfig) externalServiceCounts() []protocolCount {
+	return []protocolCount{
+		{protocol: protocolHTTP, count: c.ExternalHTTPServiceCount},
+		{protocol: protocolTCP, count: c.ExternalTCPServiceCount},
+		{protocol: protocolHTTP2, count: c.ExternalHTTP2ServiceCount},
+		{protocol: protocolGRPC, count: c.ExternalGRPCServiceCount},
+	}
+}
+
 func (c *RunnerConfig) validateServiceCounts() error {
-	if c.TCPServiceCount <= 0 && c.HTTPServiceCount <= 0 {
+	if c.TCPServiceCount <= 0 && c.HTTPServiceCount <= 0 && c.HTTP2ServiceCount <= 0 && c.GRPCServiceCount <= 0 && c.NativeServiceCount <= 0 {
 		return errors.New("service counts must be greater than or equal to 1")
 	}
 	if c.ServiceDuplicates <= 0 {
@@ -156,6 +371,157 @@ func (c *RunnerConfig) validateServiceCounts() error {
 	return nil
 }
 
+// meshServiceProtocols returns the protocols of every mesh and connect-native service keyed by name
+func (c *RunnerConfig) meshServiceProtocols() map[string]string {
+	protocols := make(map[string]string)
+	for _, protocol := range c.meshServiceCounts() {
+		for i := 1; i <= protocol.count; i++ {
+			protocols[serviceName(protocol.protocol, i)] = protocol.protocol
+		}
+	}
+	for i := 1; i <= c.NativeServiceCount; i++ {
+		protocols[nativeServiceName(i)] = protocolHTTP
+	}
+	return protocols
+}
+
+// serviceProtocols returns the protocols of every service keyed by name
+func (c *RunnerConfig) serviceProtocols() map[string]string {
+	protocols := c.meshServiceProtocols()
+	for _, protocol := range c.externalServiceCounts() {
+		for i := 1; i <= protocol.count; i++ {
+			protocols[externalServiceName(protocol.protocol, i)] = protocol.protocol
+		}
+	}
+	return protocols
+}
+
+func (c *RunnerConfig) validateCalls() error {
+	meshProtocols := c.meshServiceProtocols()
+	protocols := c.serviceProtocols()
+	for name, upstreams := range c.Calls {
+		// only mesh services are run by us, so they're the only ones that can make calls
+		protocol, ok := meshProtocols[name]
+		if !ok {
+			if _, ok := protocols[name]; ok {
+				return fmt.Errorf("external service %q cannot make calls", name)
+			}
+			return fmt.Errorf("unknown service %q making calls", name)
+		}
+		for i := 1; i <= c.NativeServiceCount; i++ {
+			if name == nativeServiceName(i) {
+				return fmt.Errorf("connect-native service %q cannot make calls", name)
+			}
+		}
+		if protocol != protocolHTTP && protocol != protocolHTTP2 {
+			return fmt.Errorf("service %q must be http or http2 to make calls", name)
+		}
+		seen := make(map[string]struct{})
+		for _, upstream := range upstreams {
+			if _, ok := protocols[upstream]; !ok {
+				return fmt.Errorf("unknown upstream %q called by %q", upstream, name)
+			}
+			if upstream == name {
+				return fmt.Errorf("service %q cannot call itself", name)
+			}
+			if _, ok := seen[upstream]; ok {
+				return fmt.Errorf("upstream %q called more than once by %q", upstream, name)
+			}
+			seen[upstream] = struct{}{}
+		}
+	}
+
+	// a cycle would have services calling each other forever
+	visiting := make(map[string]bool)
+	var visit func(name string) error
+	visit = func(name string) error {
+		done, seen := visiting[name]
+		if seen && !done {
+			return fmt.Errorf("calls from %q form a cycle", name)
+		}
+		if seen {
+			return nil
+		}
+		visiting[name] = false
+		for _, upstream := range c.Calls[name] {
+			if err := visit(upstream); err != nil {
+				return err
+			}
+		}
+		visiting[name] = true
+		return nil
+	}
+	for name := range c.Calls {
+		if err := visit(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// callsFor returns the upstreams called by the given service
+func (c *RunnerConfig) callsFor(name string) []upstream {
+	protocols := c.serviceProtocols()
+
+	calls := []upstream{}
+	for _, called := range c.Calls[name] {
+		calls = append(calls, upstream{
+			Name:     called,
+			Protocol: protocols[called],
+		})
+	}
+	return calls
+}
+
+func (c *RunnerConfig) validateTerminatingGateway() error {
+	if c.TerminatingGatewayTLS && !c.TerminatingGateway {
+		return errors.New("external service TLS requires the terminating gateway to be enabled")
+	}
+	return nil
+}
+
+func (c *RunnerConfig) validateSidecars() error {
+	meshServices := make(map[string]struct{})
+	for _, protocol := range c.meshServiceCounts() {
+		for i := 1; i <= protocol.count; i++ {
+			meshServices[serviceName(protocol.protocol, i)] = struct{}{}
+		}
+	}
+
+	needsDataplane := false
+	for name, sidecar := range c.Sidecars {
+		if _, ok := meshServices[name]; !ok {
+			return fmt.Errorf("unknown mesh service %q given a sidecar", name)
+		}
+		switch sidecar {
+		case sidecarEnvoy, sidecarBuiltin:
+		case sidecarDataplane:
+			needsDataplane = true
+		default:
+			return fmt.Errorf("invalid sidecar %q for %q, must be one of envoy, builtin or dataplane", sidecar, name)
+		}
+	}
+
+	// only require consul-dataplane to be installed if it's used
+	if !needsDataplane {
+		return nil
+	}
+	dataplane, err := findBinary(c.DataplaneBinary, defaultDataplaneBinaryPath, dataplaneBinaryName)
+	if err != nil {
+		return err
+	}
+	c.consulCommand.DataplaneBinary = dataplane
+	return nil
+}
+
+// sidecarFor returns the sidecar run for the given service
+func (c *RunnerConfig) sidecarFor(name string) string {
+	if sidecar := c.Sidecars[name]; sidecar != "" {
+		return sidecar
+	}
+	return sidecarEnvoy
+}
+
 func (c *RunnerConfig) validateSocket() error {
 	_, err := os.Stat(c.Socket)
 	if !os.IsNotExist(err) {
@@ -0,0 +1,75 @@
+package pkg
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateCalls(t *testing.T) {
+	for name, tt := range map[string]struct {
+		calls map[string][]string
+		err   string
+	}{
+		"chain": {
+			calls: map[string][]string{"http-1": {"http-2"}, "http-2": {"http-3"}},
+		},
+		"diamond": {
+			calls: map[string][]string{"http-1": {"http-2", "http-3"}, "http-2": {"http-3"}},
+		},
+		"external upstream": {
+			calls: map[string][]string{"http-1": {"http-external-1", "tcp-1"}},
+		},
+		"self": {
+			calls: map[string][]string{"http-1": {"http-1"}},
+			err:   `service "http-1" cannot call itself`,
+		},
+		"two service cycle": {
+			calls: map[string][]string{"http-1": {"http-2"}, "http-2": {"http-1"}},
+			err:   "form a cycle",
+		},
+		"three service cycle": {
+			calls: map[string][]string{"http-1": {"http-2"}, "http-2": {"http-3"}, "http-3": {"http-1"}},
+			err:   "form a cycle",
+		},
+		"duplicate upstream": {
+			calls: map[string][]string{"http-1": {"http-2", "http-2"}},
+			err:   `upstream "http-2" called more than once by "http-1"`,
+		},
+		"unknown upstream": {
+			calls: map[string][]string{"http-1": {"http-4"}},
+			err:   `unknown upstream "http-4" called by "http-1"`,
+		},
+		"unknown caller": {
+			calls: map[string][]string{"http-4": {"http-1"}},
+			err:   `unknown service "http-4" making calls`,
+		},
+		"external caller": {
+			calls: map[string][]string{"http-external-1": {"http-1"}},
+			err:   `external service "http-external-1" cannot make calls`,
+		},
+		"tcp caller": {
+			calls: map[string][]string{"tcp-1": {"http-1"}},
+			err:   `service "tcp-1" must be http or http2 to make calls`,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			config := &RunnerConfig{
+				HTTPServiceCount:         3,
+				TCPServiceCount:          1,
+				ExternalHTTPServiceCount: 1,
+				Calls:                    tt.calls,
+			}
+
+			err := config.validateCalls()
+			if tt.err == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("expected error containing %q, got: %v", tt.err, err)
+			}
+		})
+	}
+}
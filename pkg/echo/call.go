@@ -0,0 +1,35 @@
+package echo
+
+// Call is a node in the tree of responses returned by services that call their
+// upstreams, each node is the response of a single service instance. Echo
+// responses decode into a Call without any nested calls.
+type Call struct {
+	// Upstream is the name of the upstream that was called, empty for the
+	// service that received the original request
+	Upstream string `json:",omitempty"`
+	// ID is the id of the service instance that responded
+	ID string `json:",omitempty"`
+	// Status is the HTTP status of the response, 0 for TCP upstreams
+	Status int `json:",omitempty"`
+	// Latency is how long the call took
+	Latency string `json:",omitempty"`
+	// TraceID is the id of the trace propagated through the calls
+	TraceID string `json:",omitempty"`
+	// Error is the reason the call failed, if it did
+	Error string `json:",omitempty"`
+	// Calls are the calls made by the service instance to its own upstreams
+	Calls []*Call `json:",omitempty"`
+}
+
+// Failed returns whether the call, or any of the calls it made, failed.
+func (c *Call) Failed() bool {
+	if c.Error != "" || c.Status >= 400 {
+		return true
+	}
+	for _, call := range c.Calls {
+		if call.Failed() {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,145 @@
+package echo
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+const (
+	// GRPCService is the fully qualified name of the gRPC echo service
+	GRPCService = "consulservices.echo.Echo"
+	// GRPCMethod is the full name of the method that returns an echo response
+	GRPCMethod = "/" + GRPCService + "/Echo"
+
+	grpcFile = "consulservices/echo.proto"
+)
+
+// the echo service has no generated code, so register its descriptor by hand
+// so that it can be discovered through server reflection
+func init() {
+	file, err := protodesc.NewFile(&descriptorpb.FileDescriptorProto{
+		Name:       proto.String(grpcFile),
+		Package:    proto.String("consulservices.echo"),
+		Syntax:     proto.String("proto3"),
+		Dependency: []string{"google/protobuf/empty.proto", "google/protobuf/struct.proto"},
+		Service: []*descriptorpb.ServiceDescriptorProto{{
+			Name: proto.String("Echo"),
+			Method: []*descriptorpb.MethodDescriptorProto{{
+				Name:       proto.String("Echo"),
+				InputType:  proto.String(".google.protobuf.Empty"),
+				OutputType: proto.String(".google.protobuf.Struct"),
+			}},
+		}},
+	}, protoregistry.GlobalFiles)
+	if err != nil {
+		panic(err)
+	}
+	if err := protoregistry.GlobalFiles.RegisterFile(file); err != nil {
+		panic(err)
+	}
+}
+
+// grpcEchoServer is the handler type of the gRPC echo service
+type grpcEchoServer interface {
+	echo(ctx context.Context) (*structpb.Struct, error)
+}
+
+type grpcEcho func(ctx context.Context) *Response
+
+func (e grpcEcho) echo(ctx context.Context) (*structpb.Struct, error) {
+	data, err := json.Marshal(e(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	fields := map[string]interface{}{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	return structpb.NewStruct(fields)
+}
+
+// RegisterGRPC registers the echo service on the gRPC server, respond is called
+// to create the echo response for every request.
+func RegisterGRPC(server *grpc.Server, respond func(ctx context.Context) *Response) {
+	server.RegisterService(&grpc.ServiceDesc{
+		ServiceName: GRPCService,
+		HandlerType: (*grpcEchoServer)(nil),
+		Methods: []grpc.MethodDesc{{
+			MethodName: "Echo",
+			Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+				if err := dec(&emptypb.Empty{}); err != nil {
+					return nil, err
+				}
+
+				handler := func(ctx context.Context, _ interface{}) (interface{}, error) {
+					return srv.(grpcEchoServer).echo(ctx)
+				}
+				if interceptor == nil {
+					return handler(ctx, nil)
+				}
+				return interceptor(ctx, &emptypb.Empty{}, &grpc.UnaryServerInfo{
+					Server:     srv,
+					FullMethod: GRPCMethod,
+				}, handler)
+			},
+		}},
+		Metadata: grpcFile,
+	}, grpcEcho(respond))
+}
+
+// NewGRPCResponse creates an echo response for the gRPC request in the context.
+func NewGRPCResponse(id, datacenter string, ctx context.Context) *Response {
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	headers := make(map[string][]string)
+	for key, values := range md {
+		if !strings.HasPrefix(key, ":") {
+			headers[key] = values
+		}
+	}
+
+	method, _ := grpc.Method(ctx)
+	response := &Response{
+		ID:         id,
+		Datacenter: datacenter,
+		Method:     "POST",
+		Proto:      "HTTP/2.0",
+		Host:       first(md.Get(":authority")),
+		Path:       method,
+		Headers:    headers,
+	}
+
+	if uri := ClientURI(first(md.Get(ClientCertificateHeader))); uri != "" {
+		response.Downstream, _ = ParseSPIFFEID(uri)
+	}
+
+	return response
+}
+
+// CallGRPC calls the echo service over the connection, returning the echo
+// response encoded as JSON.
+func CallGRPC(ctx context.Context, conn *grpc.ClientConn) ([]byte, error) {
+	response := &structpb.Struct{}
+	if err := conn.Invoke(ctx, GRPCMethod, &emptypb.Empty{}, response); err != nil {
+		return nil, err
+	}
+	return json.Marshal(response.AsMap())
+}
+
+func first(values []string) string {
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
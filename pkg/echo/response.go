@@ -0,0 +1,60 @@
+package echo
+
+import (
+	"net/http"
+	"strings"
+)
+
+// Response is the body returned by test services when asked to echo a request.
+type Response struct {
+	// ID is the id of the service instance that handled the request
+	ID string
+	// Datacenter is the datacenter of the service instance
+	Datacenter string
+	// Group is the name of the instance group the service instance belongs to, if any
+	Group string `json:",omitempty"`
+	// Tags are the tags the service instance was registered with
+	Tags []string `json:",omitempty"`
+	// Meta is the metadata the service instance was registered with
+	Meta map[string]string `json:",omitempty"`
+	// Method is the method of the request
+	Method string
+	// Proto is the HTTP protocol version of the request
+	Proto string `json:",omitempty"`
+	// Host is the host of the request
+	Host string
+	// Path is the path and query of the request
+	Path string
+	// Headers are the headers of the request
+	Headers map[string][]string
+	// Downstream is the identity of the caller as forwarded by envoy, if any
+	Downstream *SPIFFEID `json:",omitempty"`
+}
+
+// Requested returns whether the request asks for an echo response.
+func Requested(r *http.Request) bool {
+	return strings.Contains(r.Header.Get("Accept"), "application/json")
+}
+
+// NewResponse creates an echo response for the given request.
+func NewResponse(id, datacenter string, r *http.Request) *Response {
+	response := &Response{
+		ID:         id,
+		Datacenter: datacenter,
+		Method:     r.Method,
+		Proto:      r.Proto,
+		Host:       r.Host,
+		Path:       r.URL.RequestURI(),
+		Headers:    r.Header.Clone(),
+	}
+
+	if uri := ClientURI(r.Header.Get(ClientCertificateHeader)); uri != "" {
+		// if we can't parse the identity, it's still visible in the headers
+		response.Downstream, _ = ParseSPIFFEID(uri)
+	} else if r.TLS != nil && len(r.TLS.PeerCertificates) > 0 && len(r.TLS.PeerCertificates[0].URIs) > 0 {
+		// connect-native services terminate mTLS themselves
+		response.Downstream, _ = ParseSPIFFEID(r.TLS.PeerCertificates[0].URIs[0].String())
+	}
+
+	return response
+}
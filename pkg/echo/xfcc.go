@@ -0,0 +1,99 @@
+package echo
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+)
+
+// ClientCertificateHeader is the header envoy uses to forward information
+// about the downstream's client certificate.
+const ClientCertificateHeader = "X-Forwarded-Client-Cert"
+
+// SPIFFEID is a parsed Consul service identity.
+type SPIFFEID struct {
+	URI         string
+	TrustDomain string
+	Partition   string `json:",omitempty"`
+	Namespace   string
+	Datacenter  string
+	Service     string
+}
+
+// ParseSPIFFEID parses a Consul service SPIFFE ID of the form
+// spiffe://<trust-domain>[/ap/<partition>]/ns/<namespace>/dc/<datacenter>/svc/<service>.
+func ParseSPIFFEID(uri string) (*SPIFFEID, error) {
+	parsed, err := url.Parse(uri)
+	if err != nil {
+		return nil, err
+	}
+	if parsed.Scheme != "spiffe" {
+		return nil, errors.New("not a spiffe id")
+	}
+
+	id := &SPIFFEID{
+		URI:         uri,
+		TrustDomain: parsed.Host,
+	}
+
+	segments := strings.Split(strings.Trim(parsed.Path, "/"), "/")
+	if len(segments)%2 != 0 {
+		return nil, errors.New("malformed spiffe id")
+	}
+	for i := 0; i < len(segments); i += 2 {
+		value := segments[i+1]
+		switch segments[i] {
+		case "ap":
+			id.Partition = value
+		case "ns":
+			id.Namespace = value
+		case "dc":
+			id.Datacenter = value
+		case "svc":
+			id.Service = value
+		}
+	}
+	if id.Service == "" {
+		return nil, errors.New("spiffe id is not for a service")
+	}
+
+	return id, nil
+}
+
+// ClientURI returns the URI of the downstream's certificate from an
+// x-forwarded-client-cert header value. If multiple proxies have appended
+// to the header, the last one, which is the immediate downstream, wins.
+func ClientURI(header string) string {
+	uri := ""
+	for _, element := range splitQuoted(header, ',') {
+		for _, pair := range splitQuoted(element, ';') {
+			key, value, found := strings.Cut(pair, "=")
+			if found && strings.EqualFold(strings.TrimSpace(key), "uri") {
+				uri = strings.Trim(strings.TrimSpace(value), `"`)
+			}
+		}
+	}
+	return uri
+}
+
+// splitQuoted splits s on sep, ignoring any separators inside of double quotes
+func splitQuoted(s string, sep rune) []string {
+	parts := []string{}
+	quoted := false
+	escaped := false
+	start := 0
+	for i, r := range s {
+		switch {
+		case escaped:
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == '"':
+			quoted = !quoted
+		case r == sep && !quoted:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
@@ -3,9 +3,12 @@ package pkg
 import (
 	"bytes"
 	"context"
+	"fmt"
 	"os"
 	"path"
+	"strings"
 	"text/template"
+	"time"
 
 	"github.com/andrewstucki/consul-services/pkg/commands"
 	"github.com/andrewstucki/consul-services/pkg/server"
@@ -34,6 +37,31 @@ type ConsulConfigEntry struct {
 	locality locality
 }
 
+// newGeneratedEntry renders one of our own templates into a definition file for a config
+// entry, the definition is then rendered again with the tracker of the entry like any
+// resource file would be
+func newGeneratedEntry(command *ConsulCommand, server *server.Server, locality locality, kind, name, templateName string, args interface{}) (*ConsulConfigEntry, error) {
+	var buffer bytes.Buffer
+	if err := getTemplate(templateName).Execute(&buffer, args); err != nil {
+		return nil, err
+	}
+
+	definition := path.Join(locality.Datacenter, "generated", fmt.Sprintf("%s-%s.hcl", kind, name))
+	if err := vfs.WriteFile(definition, buffer.Bytes(), 0600); err != nil {
+		return nil, err
+	}
+
+	return &ConsulConfigEntry{
+		ConsulCommand:  command,
+		Kind:           kind,
+		Name:           name,
+		DefinitionFile: vfs.PathFor(definition),
+		Server:         server,
+		tracker:        newTracker(),
+		locality:       locality,
+	}, nil
+}
+
 func (c *ConsulConfigEntry) renderedFile() string {
 	return path.Join(c.locality.Datacenter, "entries", path.Base(c.DefinitionFile))
 }
@@ -62,6 +90,42 @@ func (c *ConsulConfigEntry) Write(ctx context.Context) error {
 	))
 }
 
+// rotationInterval returns how often the entry should be re-rendered and
+// re-applied, 0 if it was never marked for rotation
+func (c *ConsulConfigEntry) rotationInterval() time.Duration {
+	return c.tracker.rotation
+}
+
+// Rotate periodically re-renders the entry, issuing fresh certificates, and
+// re-applies it until the context is canceled
+func (c *ConsulConfigEntry) Rotate(ctx context.Context) error {
+	ticker := time.NewTicker(c.rotationInterval())
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			previous := c.tracker.serialNumbers()
+			if err := c.Write(ctx); err != nil {
+				if ctx.Err() != nil {
+					return nil
+				}
+				// the agent may just be briefly unavailable, so try again on the next tick
+				c.Logger.Error("error rotating config entry certificates", "kind", c.Kind, "name", c.Name, "err", err)
+				continue
+			}
+			c.Logger.Info("rotated config entry certificates",
+				"kind", c.Kind,
+				"name", c.Name,
+				"old-serial", strings.Join(previous, ","),
+				"new-serial", strings.Join(c.tracker.serialNumbers(), ","),
+			)
+		}
+	}
+}
+
 func (c *ConsulConfigEntry) renderTemplate(template, name string) error {
 	rendered, err := c.executeTemplate(template)
 	if err != nil {
@@ -82,6 +146,7 @@ func (c *ConsulConfigEntry) executeTemplate(name string) ([]byte, error) {
 		return nil, err
 	}
 
+	c.tracker.beginRender()
 	if err := template.Execute(&buffer, c.tracker); err != nil {
 		return nil, err
 	}
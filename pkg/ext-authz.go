@@ -0,0 +1,245 @@
+package pkg
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"sync"
+
+	"github.com/andrewstucki/consul-services/pkg/authz"
+	"github.com/andrewstucki/consul-services/pkg/commands"
+	"github.com/andrewstucki/consul-services/pkg/server"
+	"github.com/andrewstucki/consul-services/pkg/vfs"
+	"golang.org/x/sync/errgroup"
+)
+
+const (
+	extAuthzServiceName = "ext-authz"
+	extAuthzKind        = "ext-authz"
+)
+
+var (
+	extAuthz      *ConsulExtAuthzService
+	extAuthzMutex sync.RWMutex
+)
+
+// ConsulExtAuthzService is an external authorization service for envoy's ext_authz filter
+// run on the Consul service mesh, its policy is managed through the control server.
+type ConsulExtAuthzService struct {
+	*ConsulCommand
+
+	// ID is the id of the service to run
+	ID string
+	// OnRegister is a channel to write back to when we've registered our services
+	OnRegister chan struct{}
+	// Server is used for service registration
+	Server *server.Server
+
+	// adminPort is the port allocated for envoy's admin interface
+	adminPort int
+	// proxyPort is the port allocated for envoy's proxy interface
+	proxyPort int
+	// httpPort is the port allocated for the HTTP ext_authz protocol
+	httpPort int
+	// grpcPort is the port allocated for the gRPC ext_authz protocol, it's
+	// the port registered with Consul
+	grpcPort int
+	// tracker holds any dynamic allocations
+	tracker *tracker
+
+	// locality identifies the datacenter/partition/namespace a service is deployed in
+	locality locality
+}
+
+// newConsulExtAuthzService allocates the ports for the ext_authz service up front so that
+// they can be referenced when rendering resource files.
+func newConsulExtAuthzService(command *ConsulCommand, server *server.Server, onRegister chan struct{}, locality locality) (*ConsulExtAuthzService, error) {
+	service := &ConsulExtAuthzService{
+		ConsulCommand: command,
+		ID:            fmt.Sprintf("%s-%s", extAuthzServiceName, localitySuffix(locality)),
+		OnRegister:    onRegister,
+		Server:        server,
+		tracker:       newTracker(),
+		locality:      locality,
+	}
+
+	for _, port := range []*int{&service.adminPort, &service.proxyPort, &service.httpPort, &service.grpcPort} {
+		allocated, err := freePort()
+		if err != nil {
+			return nil, err
+		}
+		*port = allocated
+	}
+
+	extAuthzMutex.Lock()
+	defer extAuthzMutex.Unlock()
+	extAuthz = service
+
+	return service, nil
+}
+
+// extAuthzAddress returns the loopback address the running ext_authz service
+// serves the given protocol on.
+func extAuthzAddress(protocol string) (string, error) {
+	extAuthzMutex.RLock()
+	defer extAuthzMutex.RUnlock()
+
+	if extAuthz == nil {
+		return "", errors.New("ext_authz service is not enabled, run with --ext-authz")
+	}
+
+	switch protocol {
+	case "grpc":
+		return fmt.Sprintf("127.0.0.1:%d", extAuthz.grpcPort), nil
+	case "http":
+		return fmt.Sprintf("http://127.0.0.1:%d", extAuthz.httpPort), nil
+	default:
+		return "", fmt.Errorf("unsupported ext_authz protocol %q, must be grpc or http", protocol)
+	}
+}
+
+// Run runs the ext_authz service
+func (c *ConsulExtAuthzService) Run(ctx context.Context) error {
+	if err := c.renderServiceDefaults(); err != nil {
+		return err
+	}
+	if err := c.renderService(); err != nil {
+		return err
+	}
+	if err := c.renderServiceProxy(); err != nil {
+		return err
+	}
+
+	for _, file := range []string{c.serviceFile(), c.serviceProxyFile()} {
+		if err := c.runConsulBinary(ctx, nil, commands.RegisterServiceArgs(
+			c.locality.Datacenter,
+			c.locality.getAddress(),
+			vfs.PathFor(file),
+		)); err != nil {
+			return err
+		}
+	}
+	if err := c.runConsulBinary(ctx, nil, commands.WriteConfigArgs(
+		c.locality.Datacenter,
+		c.locality.getAddress(),
+		vfs.PathFor(c.serviceDefaultsFile()),
+	)); err != nil {
+		return err
+	}
+
+	logger, logFile, err := c.createServiceLogger(c.ID)
+	if err != nil {
+		return err
+	}
+	defer logFile.Close()
+
+	requests := server.NewRequestLog(defaultRequestLogSize)
+
+	c.OnRegister <- struct{}{}
+	c.Server.Register(server.Service{
+		Datacenter: c.locality.Datacenter,
+		Partition:  c.locality.Partition,
+		Namespace:  c.locality.Namespace,
+		Kind:       extAuthzKind,
+		Name:       c.ID,
+		Ports:      []int{c.grpcPort, c.httpPort},
+		NamedPorts: map[string]int{
+			"grpc": c.grpcPort,
+			"http": c.httpPort,
+		},
+		Logs:     logFile.Name(),
+		Requests: requests,
+	})
+
+	group, ctx := errgroup.WithContext(ctx)
+	group.Go(func() error {
+		return c.runEnvoy(ctx)
+	})
+	group.Go(func() error {
+		c.Logger.Info("running ext_authz service", "admin", c.adminPort, "grpc", c.grpcPort, "http", c.httpPort, "proxy", c.proxyPort)
+
+		service := &authz.Server{
+			Logger:   logger,
+			Policy:   c.Server.Authz,
+			Requests: requests,
+			HTTPPort: c.httpPort,
+			GRPCPort: c.grpcPort,
+		}
+		return service.Run(ctx)
+	})
+
+	return group.Wait()
+}
+
+func (c *ConsulExtAuthzService) runEnvoy(ctx context.Context) error {
+	c.Logger.Info("running ext_authz sidecar")
+
+	return c.runConsulBinary(ctx, func(log string) {
+		c.Server.Register(server.Service{
+			Datacenter:              c.locality.Datacenter,
+			Partition:               c.locality.Partition,
+			Namespace:               c.locality.Namespace,
+			Kind:                    "connect-proxy",
+			Name:                    c.ID + "-proxy",
+			AdminPort:               c.adminPort,
+			Ports:                   []int{c.proxyPort},
+			Logs:                    log,
+			ServiceDefaultsFile:     c.serviceDefaultsFile(),
+			ServiceProxyFile:        c.serviceProxyFile(),
+			ServiceRegistrationFile: c.serviceFile(),
+			ConsulAddress:           c.locality.getAddress(),
+			Protocol:                protocolGRPC,
+			ServicePort:             c.grpcPort,
+		})
+	}, commands.SidecarArgs(
+		c.locality.getAddress(),
+		c.ID,
+		c.adminPort,
+	))
+}
+
+func (c *ConsulExtAuthzService) renderService() error {
+	return c.renderTemplate(serviceTemplate, c.serviceFile())
+}
+
+func (c *ConsulExtAuthzService) renderServiceDefaults() error {
+	return c.renderTemplate(serviceDefaultsTemplate, c.serviceDefaultsFile())
+}
+
+func (c *ConsulExtAuthzService) renderServiceProxy() error {
+	return c.renderTemplate(serviceProxyTemplate, c.serviceProxyFile())
+}
+
+func (c *ConsulExtAuthzService) renderTemplate(template, name string) error {
+	var buffer bytes.Buffer
+
+	// envoy calls ext_authz over gRPC, so its service-defaults need the grpc
+	// protocol for the sidecars to proxy HTTP/2 to it
+	if err := getTemplate(template).Execute(&buffer, &templateArgs{
+		tracker:          c.tracker,
+		ID:               c.ID,
+		Name:             extAuthzServiceName,
+		Protocol:         protocolGRPC,
+		ServicePort:      c.grpcPort,
+		LocalServicePort: c.grpcPort,
+		ProxyPort:        c.proxyPort,
+	}); err != nil {
+		return err
+	}
+
+	return vfs.WriteFile(name, buffer.Bytes(), 0600)
+}
+
+func (c *ConsulExtAuthzService) serviceFile() string {
+	return path.Join(c.locality.Datacenter, fmt.Sprintf("service-%s.hcl", c.ID))
+}
+
+func (c *ConsulExtAuthzService) serviceDefaultsFile() string {
+	return path.Join(c.locality.Datacenter, fmt.Sprintf("service-defaults-%s.hcl", c.ID))
+}
+
+func (c *ConsulExtAuthzService) serviceProxyFile() string {
+	return path.Join(c.locality.Datacenter, fmt.Sprintf("service-proxy-%s.hcl", c.ID))
+}
@@ -3,6 +3,7 @@ package pkg
 import (
 	"bytes"
 	"context"
+	"crypto/tls"
 	"encoding/json"
 	"fmt"
 	"path"
@@ -11,6 +12,7 @@ import (
 	"github.com/andrewstucki/consul-services/pkg/server"
 	"github.com/andrewstucki/consul-services/pkg/vfs"
 	"github.com/hashicorp/consul/api"
+	"github.com/hashicorp/go-hclog"
 )
 
 // ConsulExternalService is a service run as an external service in the Consul service mesh.
@@ -27,11 +29,19 @@ type ConsulExternalService struct {
 	OnRegister chan struct{}
 	// Server is used for service registration
 	Server *server.Server
+	// TLS serves the service over TLS with a certificate issued by the built-in CA
+	TLS bool
+	// Locality is the region and zone the instance runs in
+	Locality serviceLocality
 
 	// servicePort is the port allocated for the service
 	servicePort int
 	// tracker holds any dynamic allocations
 	tracker *tracker
+	// requests records the traffic received by the service
+	requests *server.RequestLog
+	// gate lets the service be killed or paused
+	gate *instanceGate
 
 	// locality identifies the datacenter/partition/namespace a service is deployed in
 	locality locality
@@ -60,6 +70,15 @@ func (c *ConsulExternalService) Run(ctx context.Context) error {
 		return err
 	}
 
+	logger, logFile, err := c.createServiceLogger(c.ID)
+	if err != nil {
+		return err
+	}
+	defer logFile.Close()
+
+	c.requests = server.NewRequestLog(defaultRequestLogSize)
+	c.gate = newInstanceGate()
+
 	c.OnRegister <- struct{}{}
 	c.Server.Register(server.Service{
 		Datacenter:              c.locality.Datacenter,
@@ -68,13 +87,17 @@ func (c *ConsulExternalService) Run(ctx context.Context) error {
 		Kind:                    "external",
 		Name:                    c.ID,
 		Ports:                   []int{c.servicePort},
+		Zone:                    c.Locality.Zone,
 		ServiceDefaultsFile:     c.serviceDefaultsFile(),
 		ServiceRegistrationFile: c.serviceFile(),
 		Protocol:                c.Protocol,
 		ConsulAddress:           c.locality.getAddress(),
+		Logs:                    logFile.Name(),
+		Requests:                c.requests,
+		Lifecycle:               c.gate,
 	})
 
-	return c.runService(ctx)
+	return c.runService(ctx, logger)
 }
 
 func (c *ConsulExternalService) renderService() error {
@@ -94,15 +117,12 @@ func (c *ConsulExternalService) registerService(ctx context.Context) error {
 		return err
 	}
 
-	registration := &api.CatalogRegistration{}
-	if err := json.Unmarshal(data, registration); err != nil {
-		return err
-	}
-
+	// register the raw definition since the api package's registration
+	// types don't know about service localities
 	options := &api.WriteOptions{
 		Datacenter: c.locality.Datacenter,
 	}
-	if _, err := client.Catalog().Register(registration, options.WithContext(ctx)); err != nil {
+	if _, err := client.Raw().Write("/v1/catalog/register", json.RawMessage(data), nil, options.WithContext(ctx)); err != nil {
 		return err
 	}
 
@@ -148,6 +168,7 @@ func (c *ConsulExternalService) executeTemplate(name string) ([]byte, error) {
 		Name:        c.Name,
 		Protocol:    c.Protocol,
 		ServicePort: c.servicePort,
+		Locality:    c.Locality,
 	}); err != nil {
 		return nil, err
 	}
@@ -155,14 +176,53 @@ func (c *ConsulExternalService) executeTemplate(name string) ([]byte, error) {
 	return buffer.Bytes(), nil
 }
 
-func (c *ConsulExternalService) runService(ctx context.Context) error {
-	c.Logger.Info("running service", "protocol", c.Protocol, "service", c.servicePort)
+func (c *ConsulExternalService) runService(ctx context.Context, logger hclog.Logger) error {
+	c.Logger.Info("running service", "protocol", c.Protocol, "service", c.servicePort, "tls", c.TLS)
+
+	var tlsConfig *tls.Config
+	if c.TLS {
+		var err error
+		tlsConfig, err = c.tlsConfig()
+		if err != nil {
+			return err
+		}
+	}
 
 	service := &Service{
-		ID:       c.ID,
-		Protocol: c.Protocol,
-		Port:     c.servicePort,
+		ID:         c.ID,
+		Datacenter: c.locality.Datacenter,
+		Protocol:   c.Protocol,
+		Port:       c.servicePort,
+		Logger:     logger,
+		Requests:   c.requests,
+		TLS:        tlsConfig,
+		gate:       c.gate,
 	}
 
 	return service.Run(ctx)
 }
+
+// tlsConfig issues a certificate for the service that terminating gateways
+// can verify with the built-in CA
+func (c *ConsulExternalService) tlsConfig() (*tls.Config, error) {
+	issued, err := generateCertificate(c.Name, c.Name, "localhost", "127.0.0.1")
+	if err != nil {
+		return nil, err
+	}
+
+	certificate, err := tls.X509KeyPair([]byte(issued.Chain), []byte(issued.PrivateKey))
+	if err != nil {
+		return nil, err
+	}
+
+	config := &tls.Config{
+		Certificates: []tls.Certificate{certificate},
+	}
+	switch c.Protocol {
+	case protocolHTTP:
+		config.NextProtos = []string{"http/1.1"}
+	case protocolHTTP2, protocolGRPC:
+		config.NextProtos = []string{"h2"}
+	}
+	return config, nil
+}
@@ -28,6 +28,26 @@ func (d *dummyFileArgs) GetCertificate(name string, sans ...string) *Certificate
 	return &CertificateInfo{}
 }
 
+func (d *dummyFileArgs) NewCertificate(name string, sans ...string) *certificateRequest {
+	return &certificateRequest{dryRun: true}
+}
+
+func (d *dummyFileArgs) GetJWKSURL() string {
+	return ""
+}
+
+func (d *dummyFileArgs) GetJWTIssuer() string {
+	return ""
+}
+
+func (d *dummyFileArgs) GetExtAuthzAddress(protocol string) string {
+	return ""
+}
+
+func (d *dummyFileArgs) RotateEvery(interval string) string {
+	return ""
+}
+
 func (d *dummyFileArgs) GetNamedPort(name string) (int, error) {
 	return 0, nil
 }
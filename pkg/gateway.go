@@ -2,6 +2,7 @@ package pkg
 
 import (
 	"context"
+	"strconv"
 
 	"github.com/andrewstucki/consul-services/pkg/commands"
 	"github.com/andrewstucki/consul-services/pkg/server"
@@ -26,6 +27,8 @@ type ConsulGateway struct {
 
 	// adminPort is the port allocated for envoy's admin interface
 	adminPort int
+	// listeners are the listeners read back from the written config entry
+	listeners []server.Listener
 }
 
 // Run runs the Consul gateway
@@ -38,9 +41,67 @@ func (c *ConsulGateway) Run(ctx context.Context) error {
 		return err
 	}
 
+	listeners, err := c.readListeners(ctx)
+	if err != nil {
+		// this is only informational, so don't fail the gateway over it
+		c.Logger.Warn("unable to read gateway listeners", "name", c.Name, "err", err)
+	}
+	c.listeners = listeners
+
 	return c.runEnvoy(ctx)
 }
 
+// readListeners reads the listeners of the gateway back from Consul so that
+// we get them after any defaulting that Consul does.
+func (c *ConsulGateway) readListeners(ctx context.Context) ([]server.Listener, error) {
+	client, err := c.locality.getClient()
+	if err != nil {
+		return nil, err
+	}
+
+	options := &api.QueryOptions{
+		Datacenter: c.locality.Datacenter,
+	}
+	entry, _, err := client.ConfigEntries().Get(c.Kind, c.Name, options.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	listeners := []server.Listener{}
+	switch gateway := entry.(type) {
+	case *api.APIGatewayConfigEntry:
+		for _, listener := range gateway.Listeners {
+			hostnames := []string{}
+			if listener.Hostname != "" {
+				hostnames = append(hostnames, listener.Hostname)
+			}
+			listeners = append(listeners, server.Listener{
+				Name:      listener.Name,
+				Port:      listener.Port,
+				Protocol:  listener.Protocol,
+				Hostnames: hostnames,
+				TLS:       len(listener.TLS.Certificates) > 0,
+			})
+		}
+	case *api.IngressGatewayConfigEntry:
+		for _, listener := range gateway.Listeners {
+			hostnames := []string{}
+			for _, service := range listener.Services {
+				hostnames = append(hostnames, service.Hosts...)
+			}
+			listeners = append(listeners, server.Listener{
+				Name:      strconv.Itoa(listener.Port),
+				Port:      listener.Port,
+				Protocol:  listener.Protocol,
+				Hostnames: hostnames,
+				TLS:       gateway.TLS.Enabled || (listener.TLS != nil && listener.TLS.Enabled),
+			})
+		}
+	}
+
+	return listeners, nil
+}
+
 func (c *ConsulGateway) gatewayKind() string {
 	return knownGateways[c.Kind]
 }
@@ -86,6 +147,7 @@ func (c *ConsulGateway) runEnvoy(ctx context.Context) error {
 			NamedPorts:     c.tracker.namedPorts,
 			Logs:           log,
 			ConsulAddress:  c.locality.getAddress(),
+			Listeners:      c.listeners,
 			RegisteredPort: registrationPort,
 		})
 	}, commands.GatewayRegistrationArgs(c.gatewayKind(), c.Name, c.locality.getAddress(), c.adminPort, registrationPort))
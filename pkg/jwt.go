@@ -0,0 +1,63 @@
+package pkg
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/andrewstucki/consul-services/pkg/jwt"
+)
+
+var (
+	jwtProvider      *jwt.Provider
+	jwtProviderMutex sync.Mutex
+	// jwtProviderCreated receives the provider once it's created so
+	// that the runner can start serving its endpoints
+	jwtProviderCreated = make(chan *jwt.Provider, 1)
+)
+
+// JWTProvider returns the local identity provider used for minting tokens,
+// generating its signing key and allocating its port the first time it's needed.
+func JWTProvider() (*jwt.Provider, error) {
+	jwtProviderMutex.Lock()
+	defer jwtProviderMutex.Unlock()
+
+	if jwtProvider != nil {
+		return jwtProvider, nil
+	}
+
+	port, err := freePort()
+	if err != nil {
+		return nil, err
+	}
+
+	provider, err := jwt.NewProvider(port)
+	if err != nil {
+		return nil, err
+	}
+	jwtProvider = provider
+	jwtProviderCreated <- provider
+	return jwtProvider, nil
+}
+
+// runJWTProvider serves the local identity provider if anything ends up needing it.
+func runJWTProvider(ctx context.Context) error {
+	select {
+	case <-ctx.Done():
+		return nil
+	case provider := <-jwtProviderCreated:
+		return provider.Run(ctx)
+	}
+}
+
+// lazyJWTMinter mints tokens with the local identity provider, creating it on the
+// first token rather than on every run.
+type lazyJWTMinter struct{}
+
+func (lazyJWTMinter) Mint(claims map[string]interface{}, ttl time.Duration) (string, error) {
+	provider, err := JWTProvider()
+	if err != nil {
+		return "", err
+	}
+	return provider.Mint(claims, ttl)
+}
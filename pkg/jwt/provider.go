@@ -0,0 +1,176 @@
+package jwt
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net"
+	"net/http"
+	"time"
+)
+
+const (
+	// JWKSPath is the path the key set is served on.
+	JWKSPath = "/.well-known/jwks.json"
+	// DiscoveryPath is the path the OpenID discovery document is served on.
+	DiscoveryPath = "/.well-known/openid-configuration"
+
+	signingAlgorithm = "RS256"
+	keyBits          = 2048
+)
+
+// Provider is a local identity provider that serves a JWKS endpoint
+// and signs tokens with its key.
+type Provider struct {
+	// Port is the port the JWKS endpoint is served on.
+	Port int
+	// KeyID identifies the signing key in the key set and token headers.
+	KeyID string
+
+	key *rsa.PrivateKey
+}
+
+// NewProvider creates a provider with a freshly generated signing key that
+// will serve on the given port.
+func NewProvider(port int) (*Provider, error) {
+	key, err := rsa.GenerateKey(rand.Reader, keyBits)
+	if err != nil {
+		return nil, err
+	}
+
+	// derive the key id from the public key so it's stable for a given key
+	thumbprint := sha256.Sum256(key.PublicKey.N.Bytes())
+
+	return &Provider{
+		Port:  port,
+		KeyID: encode(thumbprint[:8]),
+		key:   key,
+	}, nil
+}
+
+// Issuer returns the issuer tokens are minted with.
+func (p *Provider) Issuer() string {
+	return fmt.Sprintf("http://127.0.0.1:%d", p.Port)
+}
+
+// JWKSURL returns the URL that the key set is served from.
+func (p *Provider) JWKSURL() string {
+	return p.Issuer() + JWKSPath
+}
+
+// JWKS returns the JSON encoded key set for verifying minted tokens.
+func (p *Provider) JWKS() ([]byte, error) {
+	return json.Marshal(map[string]interface{}{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"use": "sig",
+			"alg": signingAlgorithm,
+			"kid": p.KeyID,
+			"n":   encode(p.key.PublicKey.N.Bytes()),
+			"e":   encode(big.NewInt(int64(p.key.PublicKey.E)).Bytes()),
+		}},
+	})
+}
+
+// Mint signs a token with the given claims. The iss, iat, nbf and exp claims
+// are filled in unless they're already set, with exp being ttl from now.
+func (p *Provider) Mint(claims map[string]interface{}, ttl time.Duration) (string, error) {
+	now := time.Now()
+
+	payload := map[string]interface{}{
+		"iss": p.Issuer(),
+		"iat": now.Unix(),
+		"nbf": now.Unix(),
+		"exp": now.Add(ttl).Unix(),
+	}
+	for claim, value := range claims {
+		payload[claim] = value
+	}
+
+	header, err := json.Marshal(map[string]string{
+		"alg": signingAlgorithm,
+		"typ": "JWT",
+		"kid": p.KeyID,
+	})
+	if err != nil {
+		return "", err
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return "", err
+	}
+
+	signingInput := encode(header) + "." + encode(body)
+	digest := sha256.Sum256([]byte(signingInput))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, p.key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", err
+	}
+
+	return signingInput + "." + encode(signature), nil
+}
+
+// Run serves the JWKS and discovery endpoints until the context is canceled.
+func (p *Provider) Run(ctx context.Context) error {
+	mux := http.NewServeMux()
+	mux.HandleFunc(JWKSPath, p.serveJWKS)
+	mux.HandleFunc(DiscoveryPath, p.serveDiscovery)
+
+	server := &http.Server{
+		Handler: mux,
+		BaseContext: func(l net.Listener) context.Context {
+			return ctx
+		},
+	}
+	defer server.Close()
+
+	listener, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", p.Port))
+	if err != nil {
+		return err
+	}
+
+	errChannel := make(chan error, 1)
+	go func() {
+		if err := server.Serve(listener); err != nil {
+			errChannel <- err
+		}
+	}()
+
+	select {
+	case <-ctx.Done():
+		return nil
+	case err := <-errChannel:
+		return err
+	}
+}
+
+func (p *Provider) serveJWKS(w http.ResponseWriter, r *http.Request) {
+	data, err := p.JWKS()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprint(w, "internal error")
+		return
+	}
+
+	w.Header().Set("content-type", "application/json")
+	w.Write(data)
+}
+
+func (p *Provider) serveDiscovery(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("content-type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"issuer":                                p.Issuer(),
+		"jwks_uri":                              p.JWKSURL(),
+		"id_token_signing_alg_values_supported": []string{signingAlgorithm},
+	})
+}
+
+func encode(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
@@ -0,0 +1,114 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+
+	"github.com/andrewstucki/consul-services/pkg/server"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// instanceGate simulates killing and pausing an in-process service, paused
+// services hold on to new connections and requests until resumed while killed
+// services stop accepting anything
+type instanceGate struct {
+	paused    bool
+	killed    bool
+	listeners []net.Listener
+	mutex     sync.Mutex
+	cond      *sync.Cond
+}
+
+func newInstanceGate() *instanceGate {
+	gate := &instanceGate{}
+	gate.cond = sync.NewCond(&gate.mutex)
+	return gate
+}
+
+// Signal implements server.Lifecycle
+func (g *instanceGate) Signal(action string) error {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	if g.killed {
+		return errors.New("instance has been killed")
+	}
+
+	switch action {
+	case server.ActionKill:
+		g.killed = true
+		for _, listener := range g.listeners {
+			listener.Close()
+		}
+	case server.ActionPause:
+		g.paused = true
+	case server.ActionResume:
+		g.paused = false
+	default:
+		return server.ValidateAction(action)
+	}
+
+	g.cond.Broadcast()
+	return nil
+}
+
+// wait blocks while the instance is paused, returning false if it's been killed
+func (g *instanceGate) wait() bool {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	for g.paused && !g.killed {
+		g.cond.Wait()
+	}
+	return !g.killed
+}
+
+// listen wraps the listener so that it honors the state of the gate
+func (g *instanceGate) listen(listener net.Listener) net.Listener {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	g.listeners = append(g.listeners, listener)
+	return &gatedListener{Listener: listener, gate: g}
+}
+
+// handler wraps an HTTP handler so that requests on existing connections honor
+// the state of the gate
+func (g *instanceGate) handler(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !g.wait() {
+			panic(http.ErrAbortHandler)
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// unaryInterceptor makes gRPC calls on existing connections honor the state of the gate
+func (g *instanceGate) unaryInterceptor(ctx context.Context, request interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if !g.wait() {
+		return nil, status.Error(codes.Unavailable, "instance has been killed")
+	}
+	return handler(ctx, request)
+}
+
+type gatedListener struct {
+	net.Listener
+	gate *instanceGate
+}
+
+func (l *gatedListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	if !l.gate.wait() {
+		conn.Close()
+		return nil, net.ErrClosed
+	}
+	return conn, nil
+}
@@ -0,0 +1,244 @@
+package load
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/andrewstucki/consul-services/pkg/echo"
+)
+
+const (
+	defaultTimeout = 5 * time.Second
+
+	// maxInstanceLength is the longest plain-text body we'll treat as an instance id
+	maxInstanceLength = 128
+
+	// maxRPS is the highest rate we can tick at, anything higher would
+	// make the interval between requests zero
+	maxRPS = int(time.Second)
+)
+
+// Config configures a load generation run.
+type Config struct {
+	// Address is the host:port to send traffic to
+	Address string
+	// Protocol is the protocol to use, either http or tcp
+	Protocol string
+	// Host overrides the Host header of HTTP requests
+	Host string
+	// Path is the path of HTTP requests
+	Path string
+	// RPS is the target number of requests per second, 0 means unlimited
+	RPS int
+	// Concurrency is the number of requests that can be in-flight at once
+	Concurrency int
+	// Duration is how long to generate traffic for
+	Duration time.Duration
+	// Timeout is the timeout for each individual request
+	Timeout time.Duration
+}
+
+// Validate validates the load generation configuration.
+func (c *Config) Validate() error {
+	if c.Address == "" {
+		return errors.New("an address must be specified")
+	}
+	if c.RPS < 0 {
+		return errors.New("rps must be greater than or equal to 0")
+	}
+	if c.RPS > maxRPS {
+		return fmt.Errorf("rps must be less than or equal to %d", maxRPS)
+	}
+	if c.Concurrency <= 0 {
+		return errors.New("concurrency must be greater than or equal to 1")
+	}
+	if c.Duration <= 0 {
+		return errors.New("duration must be greater than 0")
+	}
+	return nil
+}
+
+// Generator sends traffic to a target and records the results.
+type Generator struct {
+	config Config
+	client *http.Client
+}
+
+// New creates a new load generator.
+func New(config Config) *Generator {
+	if config.Timeout == 0 {
+		config.Timeout = defaultTimeout
+	}
+	if config.Path == "" {
+		config.Path = "/"
+	}
+
+	return &Generator{
+		config: config,
+		client: &http.Client{
+			Timeout: config.Timeout,
+			Transport: &http.Transport{
+				MaxIdleConnsPerHost: config.Concurrency,
+			},
+		},
+	}
+}
+
+// Run generates traffic until the configured duration elapses or the context
+// is canceled and returns a report of the results.
+func (g *Generator) Run(ctx context.Context) *Report {
+	ctx, cancel := context.WithTimeout(ctx, g.config.Duration)
+	defer cancel()
+
+	report := newReport()
+	tokens := g.tokens(ctx)
+
+	var wg sync.WaitGroup
+	for i := 0; i < g.config.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range tokens {
+				start := time.Now()
+				result := g.send(ctx)
+				result.latency = time.Since(start)
+
+				// don't count requests that were interrupted by us stopping
+				if ctx.Err() != nil && result.err != nil {
+					return
+				}
+				report.record(result)
+			}
+		}()
+	}
+	wg.Wait()
+
+	report.finish()
+	return report
+}
+
+// tokens returns a channel that allows a request to be sent on every receive, at
+// the configured rate
+func (g *Generator) tokens(ctx context.Context) <-chan struct{} {
+	tokens := make(chan struct{})
+
+	go func() {
+		defer close(tokens)
+
+		var tick <-chan time.Time
+		if g.config.RPS > 0 {
+			ticker := time.NewTicker(time.Second / time.Duration(g.config.RPS))
+			defer ticker.Stop()
+			tick = ticker.C
+		}
+
+		for {
+			if tick != nil {
+				select {
+				case <-ctx.Done():
+					return
+				case <-tick:
+				}
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case tokens <- struct{}{}:
+			}
+		}
+	}()
+
+	return tokens
+}
+
+type result struct {
+	status   int
+	instance string
+	group    string
+	latency  time.Duration
+	err      error
+}
+
+func (g *Generator) send(ctx context.Context) result {
+	if g.config.Protocol == "tcp" {
+		return g.sendTCP(ctx)
+	}
+	return g.sendHTTP(ctx)
+}
+
+func (g *Generator) sendTCP(ctx context.Context) result {
+	dialer := &net.Dialer{Timeout: g.config.Timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", g.config.Address)
+	if err != nil {
+		return result{err: err}
+	}
+	defer conn.Close()
+
+	if err := conn.SetDeadline(time.Now().Add(g.config.Timeout)); err != nil {
+		return result{err: err}
+	}
+
+	data, err := io.ReadAll(conn)
+	if err != nil {
+		return result{err: err}
+	}
+	if len(data) == 0 {
+		return result{err: errors.New("connection closed")}
+	}
+
+	return result{instance: strings.TrimSpace(string(data))}
+}
+
+func (g *Generator) sendHTTP(ctx context.Context) result {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("http://%s%s", g.config.Address, g.config.Path), nil)
+	if err != nil {
+		return result{err: err}
+	}
+	if g.config.Host != "" {
+		request.Host = g.config.Host
+	}
+	request.Header.Set("Accept", "application/json")
+
+	response, err := g.client.Do(request)
+	if err != nil {
+		return result{err: err}
+	}
+	defer response.Body.Close()
+
+	data, err := io.ReadAll(response.Body)
+	if err != nil {
+		return result{err: err}
+	}
+
+	instance, group := instanceFor(data)
+	return result{
+		status:   response.StatusCode,
+		instance: instance,
+		group:    group,
+	}
+}
+
+// instanceFor returns the id and group of the test service instance that
+// responded to a request, if any, our test services either echo them
+// back in JSON or return the id as the whole body
+func instanceFor(body []byte) (string, string) {
+	response := &echo.Response{}
+	if err := json.Unmarshal(body, response); err == nil {
+		return response.ID, response.Group
+	}
+
+	instance := strings.TrimSpace(string(body))
+	if len(instance) > maxInstanceLength || strings.ContainsAny(instance, "\n ") {
+		return "", ""
+	}
+	return instance, ""
+}
@@ -0,0 +1,131 @@
+package load
+
+import (
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Buckets are the upper bounds of the latency histogram buckets.
+var Buckets = []time.Duration{
+	time.Millisecond,
+	2 * time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	2500 * time.Millisecond,
+	5 * time.Second,
+}
+
+// Report is the summary of a load generation run.
+type Report struct {
+	// Total is the number of requests sent
+	Total int
+	// Errors is the number of requests that failed without a response
+	Errors int
+	// Elapsed is how long the run took
+	Elapsed time.Duration
+	// Histogram counts latencies by the bucket they fall into, the last
+	// element counts anything larger than the largest bucket
+	Histogram []int
+	// Statuses counts responses by status code, TCP connections are counted as "tcp"
+	Statuses map[string]int
+	// Instances counts responses by the instance that handled them
+	Instances map[string]int
+	// Groups counts responses by the instance group of the instance that handled them
+	Groups map[string]int
+	// ErrorMessages counts failures by error message
+	ErrorMessages map[string]int
+	// P50, P90 and P99 are latency percentiles
+	P50 time.Duration
+	P90 time.Duration
+	P99 time.Duration
+
+	start     time.Time
+	latencies []time.Duration
+	mutex     sync.Mutex
+}
+
+func newReport() *Report {
+	return &Report{
+		Histogram:     make([]int, len(Buckets)+1),
+		Statuses:      make(map[string]int),
+		Instances:     make(map[string]int),
+		Groups:        make(map[string]int),
+		ErrorMessages: make(map[string]int),
+		start:         time.Now(),
+	}
+}
+
+func (r *Report) record(result result) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.Total++
+	if result.err != nil {
+		r.Errors++
+		r.ErrorMessages[result.err.Error()]++
+		return
+	}
+
+	r.latencies = append(r.latencies, result.latency)
+	r.Histogram[bucketFor(result.latency)]++
+
+	status := "tcp"
+	if result.status != 0 {
+		status = strconv.Itoa(result.status)
+	}
+	r.Statuses[status]++
+
+	if result.instance != "" {
+		r.Instances[result.instance]++
+	}
+	if result.group != "" {
+		r.Groups[result.group]++
+	}
+}
+
+// RPS returns the achieved requests per second.
+func (r *Report) RPS() float64 {
+	if r.Elapsed == 0 {
+		return 0
+	}
+	return float64(r.Total) / r.Elapsed.Seconds()
+}
+
+func (r *Report) finish() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.Elapsed = time.Since(r.start)
+
+	sort.Slice(r.latencies, func(i, j int) bool {
+		return r.latencies[i] < r.latencies[j]
+	})
+	r.P50 = percentile(r.latencies, 0.50)
+	r.P90 = percentile(r.latencies, 0.90)
+	r.P99 = percentile(r.latencies, 0.99)
+}
+
+func bucketFor(latency time.Duration) int {
+	for i, bucket := range Buckets {
+		if latency <= bucket {
+			return i
+		}
+	}
+	return len(Buckets)
+}
+
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	index := int(float64(len(sorted)-1) * p)
+	return sorted[index]
+}
@@ -2,14 +2,18 @@ package pkg
 
 import "github.com/hashicorp/consul/api"
 
+// defaultGRPCPort is the default plaintext gRPC port of Consul
+const defaultGRPCPort = 8502
+
 type locality struct {
 	Datacenter string
 	Partition  string
 	Namespace  string
 
 	// Consul connection info
-	client  *api.Client
-	address string
+	client   *api.Client
+	address  string
+	grpcPort int
 }
 
 func (l locality) getClient() (*api.Client, error) {
@@ -27,3 +31,13 @@ func (l locality) getAddress() string {
 
 	return api.DefaultConfig().Address
 }
+
+// getGRPCAddress returns the address and port of the gRPC interface
+// of the Consul servers, used by consul-dataplane
+func (l locality) getGRPCAddress() (string, int) {
+	if l.grpcPort != 0 {
+		return "127.0.0.1", l.grpcPort
+	}
+
+	return "127.0.0.1", defaultGRPCPort
+}
@@ -0,0 +1,235 @@
+package logs
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+const defaultPollInterval = 250 * time.Millisecond
+
+// Source is a log file produced by a running component.
+type Source struct {
+	// Name is the name used to identify the component in output
+	Name string
+	// Path is the path to the log file on disk
+	Path string
+}
+
+// Line is a single line read from a log source.
+type Line struct {
+	// Source is the name of the source the line came from
+	Source string
+	// Time is the time parsed from the line, or the time it was read
+	Time time.Time
+	// Text is the content of the line without a trailing newline
+	Text string
+}
+
+// Options configures how log sources are streamed.
+type Options struct {
+	// Follow keeps reading the sources as they are written to
+	Follow bool
+	// Since filters out any lines timestamped before the given time
+	Since time.Time
+	// Grep filters out any lines not matching the expression
+	Grep *regexp.Regexp
+	// PollInterval is how often followed files are checked for new data
+	PollInterval time.Duration
+}
+
+func (o Options) matches(line Line) bool {
+	if !o.Since.IsZero() && line.Time.Before(o.Since) {
+		return false
+	}
+	if o.Grep != nil && !o.Grep.MatchString(line.Text) {
+		return false
+	}
+	return true
+}
+
+// Stream reads all of the given sources, merging their lines in timestamp order,
+// and calls fn for every line that passes the configured filters. If Follow is set
+// it continues to call fn with newly written lines until the context is canceled.
+func Stream(ctx context.Context, sources []Source, options Options, fn func(line Line)) error {
+	if options.PollInterval == 0 {
+		options.PollInterval = defaultPollInterval
+	}
+
+	readers := []*reader{}
+	defer func() {
+		for _, r := range readers {
+			r.Close()
+		}
+	}()
+
+	backlog := []Line{}
+	for _, source := range sources {
+		r, err := newReader(source)
+		if err != nil {
+			return err
+		}
+		readers = append(readers, r)
+
+		lines, err := r.readLines(false)
+		if err != nil {
+			return err
+		}
+		backlog = append(backlog, lines...)
+	}
+
+	sort.SliceStable(backlog, func(i, j int) bool {
+		return backlog[i].Time.Before(backlog[j].Time)
+	})
+	for _, line := range backlog {
+		if options.matches(line) {
+			fn(line)
+		}
+	}
+
+	if !options.Follow {
+		return nil
+	}
+
+	ticker := time.NewTicker(options.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			for _, r := range readers {
+				lines, err := r.readLines(true)
+				if err != nil {
+					return err
+				}
+				for _, line := range lines {
+					if options.matches(line) {
+						fn(line)
+					}
+				}
+			}
+		}
+	}
+}
+
+type reader struct {
+	source  Source
+	file    *os.File
+	buffer  *bufio.Reader
+	offset  int64
+	partial string
+	last    time.Time
+	modTime time.Time
+}
+
+func newReader(source Source) (*reader, error) {
+	file, err := os.Open(source.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return &reader{
+		source:  source,
+		file:    file,
+		buffer:  bufio.NewReader(file),
+		modTime: info.ModTime(),
+	}, nil
+}
+
+func (r *reader) Close() error {
+	return r.file.Close()
+}
+
+// readLines reads all of the complete lines currently available in the file,
+// if live is set then any lines without a parseable timestamp are stamped with
+// the current time rather than the time of the line before them.
+func (r *reader) readLines(live bool) ([]Line, error) {
+	if err := r.checkTruncation(); err != nil {
+		return nil, err
+	}
+
+	lines := []Line{}
+	for {
+		data, err := r.buffer.ReadString('\n')
+		r.offset += int64(len(data))
+		if errors.Is(err, io.EOF) {
+			// hold on to any partially written line until we
+			// get the rest of it
+			r.partial += data
+			return r.backfill(lines), nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		text := strings.TrimRight(r.partial+data, "\r\n")
+		r.partial = ""
+
+		timestamp, ok := parseTimestamp(text)
+		switch {
+		case ok:
+			r.last = timestamp
+		case live:
+			r.last = time.Now()
+		}
+
+		lines = append(lines, Line{
+			Source: r.source.Name,
+			Time:   r.last,
+			Text:   text,
+		})
+	}
+}
+
+// backfill stamps any leading lines that had no timestamp with the first
+// timestamp we found in the file, or the file's modification time if the
+// file has no timestamps at all.
+func (r *reader) backfill(lines []Line) []Line {
+	fill := r.modTime
+	for _, line := range lines {
+		if !line.Time.IsZero() {
+			fill = line.Time
+			break
+		}
+	}
+	for i := range lines {
+		if !lines[i].Time.IsZero() {
+			break
+		}
+		lines[i].Time = fill
+	}
+	return lines
+}
+
+func (r *reader) checkTruncation() error {
+	info, err := r.file.Stat()
+	if err != nil {
+		return err
+	}
+	if info.Size() >= r.offset {
+		return nil
+	}
+
+	// the file was truncated out from under us, start over
+	if _, err := r.file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	r.buffer.Reset(r.file)
+	r.offset = 0
+	r.partial = ""
+	return nil
+}
@@ -0,0 +1,37 @@
+package logs
+
+import (
+	"regexp"
+	"time"
+)
+
+type timestampFormat struct {
+	pattern *regexp.Regexp
+	layout  string
+}
+
+var timestampFormats = []timestampFormat{{
+	// hclog output, used by both Consul and our own services
+	pattern: regexp.MustCompile(`^(\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}\.\d{3}(?:Z|[+-]\d{4}))`),
+	layout:  "2006-01-02T15:04:05.000Z0700",
+}, {
+	// envoy's default log format
+	pattern: regexp.MustCompile(`^\[(\d{4}-\d{2}-\d{2} \d{2}:\d{2}:\d{2}\.\d{3})\]`),
+	layout:  "2006-01-02 15:04:05.000",
+}}
+
+func parseTimestamp(line string) (time.Time, bool) {
+	for _, format := range timestampFormats {
+		matches := format.pattern.FindStringSubmatch(line)
+		if matches == nil {
+			continue
+		}
+
+		timestamp, err := time.ParseInLocation(format.layout, matches[1], time.Local)
+		if err != nil {
+			continue
+		}
+		return timestamp, true
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,61 @@
+package pkg
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/andrewstucki/consul-services/pkg/server"
+	"github.com/hashicorp/consul/api"
+)
+
+const (
+	meshGatewayModeLocal  = "local"
+	meshGatewayModeRemote = "remote"
+	meshGatewayModeNone   = "none"
+
+	proxyDefaultsTemplate = "proxy-defaults.hcl"
+)
+
+func (c *RunnerConfig) validateMeshGateways() error {
+	switch c.MeshGatewayMode {
+	case "", meshGatewayModeLocal, meshGatewayModeRemote, meshGatewayModeNone:
+	default:
+		return fmt.Errorf("invalid mesh gateway mode %q, must be one of local, remote or none", c.MeshGatewayMode)
+	}
+
+	if c.WANFederation {
+		if !c.RunConsul {
+			return errors.New("federating through mesh gateways requires running Consul")
+		}
+		if len(c.Datacenters) < 2 {
+			return errors.New("federating through mesh gateways requires multiple datacenters")
+		}
+	}
+	return nil
+}
+
+// meshDefaultsArgs are used to render the generated proxy-defaults entry
+type meshDefaultsArgs struct {
+	Mode string
+}
+
+// newMeshDefaults generates the proxy-defaults entry that sets the mesh gateway mode for a
+// multi-datacenter run, unless one is already in resources. Config entries replicate from
+// the primary datacenter, so it's only written there.
+func (c *RunnerConfig) newMeshDefaults(server *server.Server, primary locality, resources []interface{}) ([]interface{}, error) {
+	for _, resource := range resources {
+		if entry, ok := resource.(*ConsulConfigEntry); ok && entry.Kind == api.ProxyDefaults {
+			return nil, nil
+		}
+	}
+
+	args := &meshDefaultsArgs{Mode: c.MeshGatewayMode}
+	if args.Mode == "" {
+		args.Mode = meshGatewayModeLocal
+	}
+	entry, err := newGeneratedEntry(c.consulCommand, server, primary, api.ProxyDefaults, api.ProxyConfigGlobal, proxyDefaultsTemplate, args)
+	if err != nil {
+		return nil, err
+	}
+	return []interface{}{entry}, nil
+}
@@ -6,13 +6,26 @@ import (
 	"fmt"
 	"path"
 
+	"github.com/andrewstucki/consul-services/pkg/chaos"
 	"github.com/andrewstucki/consul-services/pkg/commands"
 	"github.com/andrewstucki/consul-services/pkg/server"
 	"github.com/andrewstucki/consul-services/pkg/vfs"
+	"github.com/hashicorp/go-hclog"
 	"golang.org/x/sync/errgroup"
 )
 
-// ConsulMeshService is a service run on the Consul service mesh using the `consul connect envoy` command.
+const (
+	// sidecarEnvoy runs envoy with `consul connect envoy`
+	sidecarEnvoy = "envoy"
+	// sidecarBuiltin runs Consul's built-in L4 proxy with `consul connect proxy`
+	sidecarBuiltin = "builtin"
+	// sidecarDataplane runs envoy with consul-dataplane, which talks to the
+	// gRPC port of the servers rather than to an agent
+	sidecarDataplane = "dataplane"
+)
+
+// ConsulMeshService is a service run on the Consul service mesh behind a sidecar, by default
+// envoy run with the `consul connect envoy` command.
 type ConsulMeshService struct {
 	*ConsulCommand
 
@@ -27,7 +40,17 @@ type ConsulMeshService struct {
 	// Server is used for service registration
 	Server *server.Server
 	// ExternalUpstreams are the external services to add upstreams for
-	ExternalUpstreams []string
+	ExternalUpstreams []upstream
+	// Calls are the upstreams the service calls when it receives a request
+	Calls []upstream
+	// Locality is the region and zone the instance runs in
+	Locality serviceLocality
+	// Group is the instance group the instance belongs to, if any
+	Group *InstanceGroup
+	// Chaos runs a proxy between the sidecar and the service to inject network faults
+	Chaos bool
+	// Sidecar is the sidecar to run, one of envoy, builtin or dataplane
+	Sidecar string
 
 	// adminPort is the port allocated for envoy's admin interface
 	adminPort int
@@ -35,8 +58,21 @@ type ConsulMeshService struct {
 	proxyPort int
 	// servicePort is the port allocated for the service
 	servicePort int
+	// chaosPort is the port allocated for the chaos proxy, if enabled
+	chaosPort int
+	// gracefulPort is the port allocated for consul-dataplane's graceful shutdown
+	// interface, if it's the sidecar
+	gracefulPort int
+	// nodeName is the node the sidecar is registered on, used by consul-dataplane
+	nodeName string
 	// tracker holds any dynamic allocations
 	tracker *tracker
+	// requests records the traffic received by the service
+	requests *server.RequestLog
+	// gate lets the service be killed or paused
+	gate *instanceGate
+	// chaos holds the faults injected by the chaos proxy
+	chaos *server.ChaosStore
 
 	// locality identifies the datacenter/partition/namespace a service is deployed in
 	locality locality
@@ -68,6 +104,18 @@ func (c *ConsulMeshService) Run(ctx context.Context) error {
 		return err
 	}
 
+	logger, logFile, err := c.createServiceLogger(c.ID)
+	if err != nil {
+		return err
+	}
+	defer logFile.Close()
+
+	c.requests = server.NewRequestLog(defaultRequestLogSize)
+	c.gate = newInstanceGate()
+	if c.Chaos {
+		c.chaos = server.NewChaosStore()
+	}
+
 	c.OnRegister <- struct{}{}
 	c.Server.Register(server.Service{
 		Datacenter: c.locality.Datacenter,
@@ -75,16 +123,33 @@ func (c *ConsulMeshService) Run(ctx context.Context) error {
 		Namespace:  c.locality.Namespace,
 		Kind:       "service",
 		Name:       c.ID,
+		Zone:       c.Locality.Zone,
+		Group:      c.groupName(),
 		Ports:      []int{c.servicePort},
+		Logs:       logFile.Name(),
+		Requests:   c.requests,
+		Chaos:      c.chaos,
+		Lifecycle:  c.gate,
 	})
 
 	group, ctx := errgroup.WithContext(ctx)
 	group.Go(func() error {
-		return c.runEnvoy(ctx)
+		return c.runSidecar(ctx)
 	})
 	group.Go(func() error {
-		return c.runService(ctx)
+		return c.runService(ctx, logger)
 	})
+	if c.Chaos {
+		group.Go(func() error {
+			proxy := &chaos.Proxy{
+				Logger:   logger,
+				Settings: c.chaos,
+				Port:     c.chaosPort,
+				Target:   c.servicePort,
+			}
+			return proxy.Run(ctx)
+		})
+	}
 
 	return group.Wait()
 }
@@ -106,9 +171,40 @@ func (c *ConsulMeshService) allocatePorts() error {
 	c.adminPort = adminPort
 	c.proxyPort = proxyPort
 	c.servicePort = servicePort
+
+	if c.Chaos {
+		chaosPort, err := freePort()
+		if err != nil {
+			return err
+		}
+		c.chaosPort = chaosPort
+	}
+
+	if c.Sidecar == sidecarDataplane {
+		gracefulPort, err := freePort()
+		if err != nil {
+			return err
+		}
+		c.gracefulPort = gracefulPort
+	}
 	return nil
 }
 
+func (c *ConsulMeshService) groupName() string {
+	if c.Group == nil {
+		return ""
+	}
+	return c.Group.Name
+}
+
+// localServicePort is the port that the sidecar sends traffic to
+func (c *ConsulMeshService) localServicePort() int {
+	if c.Chaos {
+		return c.chaosPort
+	}
+	return c.servicePort
+}
+
 func (c *ConsulMeshService) registerService(ctx context.Context) error {
 	c.Logger.Info("registering service", "id", c.ID)
 
@@ -139,44 +235,135 @@ func (c *ConsulMeshService) writeServiceDefaults(ctx context.Context) error {
 	))
 }
 
-func (c *ConsulMeshService) runService(ctx context.Context) error {
+func (c *ConsulMeshService) runService(ctx context.Context, logger hclog.Logger) error {
 	c.Logger.Info("running service", "protocol", c.Protocol, "admin", c.adminPort, "service", c.servicePort, "proxy", c.proxyPort)
 
 	service := &Service{
-		ID:       c.ID,
-		Protocol: c.Protocol,
-		Port:     c.servicePort,
+		ID:         c.ID,
+		Datacenter: c.locality.Datacenter,
+		Protocol:   c.Protocol,
+		Port:       c.servicePort,
+		Logger:     logger,
+		Requests:   c.requests,
+		Group:      c.Group,
+		Calls:      c.serviceCalls(),
+		gate:       c.gate,
 	}
 
 	return service.Run(ctx)
 }
 
-func (c *ConsulMeshService) runEnvoy(ctx context.Context) error {
-	c.Logger.Info("running sidecar")
-
-	return c.runConsulBinary(ctx, func(log string) {
-		c.Server.Register(server.Service{
-			Datacenter:              c.locality.Datacenter,
-			Partition:               c.locality.Partition,
-			Namespace:               c.locality.Namespace,
-			Kind:                    "connect-proxy",
-			Name:                    c.ID + "-proxy",
-			AdminPort:               c.adminPort,
-			Ports:                   append([]int{c.proxyPort}, c.tracker.ports...),
-			NamedPorts:              c.tracker.namedPorts,
-			Logs:                    log,
-			ServiceDefaultsFile:     c.serviceDefaultsFile(),
-			ServiceProxyFile:        c.serviceProxyFile(),
-			ServiceRegistrationFile: c.serviceFile(),
-			ConsulAddress:           c.locality.getAddress(),
-			Protocol:                c.Protocol,
-			ServicePort:             c.servicePort,
+func (c *ConsulMeshService) runSidecar(ctx context.Context) error {
+	c.Logger.Info("running sidecar", "sidecar", c.Sidecar)
+
+	switch c.Sidecar {
+	case sidecarBuiltin:
+		return c.runConsulBinary(ctx, c.registerSidecar, commands.BuiltinSidecarArgs(
+			c.locality.getAddress(),
+			c.ID,
+		))
+	case sidecarDataplane:
+		// consul-dataplane looks up the proxy by the node that the agent synced it to
+		client, err := c.locality.getClient()
+		if err != nil {
+			return err
+		}
+		c.nodeName, err = client.Agent().NodeName()
+		if err != nil {
+			return err
+		}
+
+		grpcAddress, grpcPort := c.locality.getGRPCAddress()
+		return c.runBinary(ctx, c.DataplaneBinary, c.registerSidecar, commands.DataplaneArgs(
+			grpcAddress,
+			grpcPort,
+			c.nodeName,
+			c.ID+"-proxy",
+			c.adminPort,
+			c.gracefulPort,
+		))
+	default:
+		return c.runConsulBinary(ctx, c.registerSidecar, commands.SidecarArgs(
+			c.locality.getAddress(),
+			c.ID,
+			c.adminPort,
+		))
+	}
+}
+
+func (c *ConsulMeshService) registerSidecar(log string) {
+	// the built-in proxy has no admin interface
+	adminPort := c.adminPort
+	if c.Sidecar == sidecarBuiltin {
+		adminPort = 0
+	}
+
+	_, grpcPort := c.locality.getGRPCAddress()
+	c.Server.Register(server.Service{
+		Datacenter:              c.locality.Datacenter,
+		Partition:               c.locality.Partition,
+		Namespace:               c.locality.Namespace,
+		Kind:                    "connect-proxy",
+		Name:                    c.ID + "-proxy",
+		Sidecar:                 c.Sidecar,
+		AdminPort:               adminPort,
+		Ports:                   append([]int{c.proxyPort}, c.tracker.ports...),
+		NamedPorts:              c.tracker.namedPorts,
+		Logs:                    log,
+		ServiceDefaultsFile:     c.serviceDefaultsFile(),
+		ServiceProxyFile:        c.serviceProxyFile(),
+		ServiceRegistrationFile: c.serviceFile(),
+		ConsulAddress:           c.locality.getAddress(),
+		ConsulGRPCPort:          grpcPort,
+		NodeName:                c.nodeName,
+		GracefulPort:            c.gracefulPort,
+		Protocol:                c.Protocol,
+		ServicePort:             c.servicePort,
+		Upstreams:               c.upstreamProtocols(),
+		Zone:                    c.Locality.Zone,
+	})
+}
+
+func (c *ConsulMeshService) upstreamProtocols() map[string]string {
+	protocols := make(map[string]string)
+	for _, upstream := range c.ExternalUpstreams {
+		protocols[upstream.Name] = upstream.Protocol
+	}
+	for _, upstream := range c.meshUpstreams() {
+		protocols[upstream.Name] = upstream.Protocol
+	}
+	return protocols
+}
+
+// meshUpstreams are the upstreams called by the service that aren't already
+// added as external upstreams
+func (c *ConsulMeshService) meshUpstreams() []upstream {
+	external := make(map[string]struct{})
+	for _, upstream := range c.ExternalUpstreams {
+		external[upstream.Name] = struct{}{}
+	}
+
+	upstreams := []upstream{}
+	for _, upstream := range c.Calls {
+		if _, ok := external[upstream.Name]; !ok {
+			upstreams = append(upstreams, upstream)
+		}
+	}
+	return upstreams
+}
+
+// serviceCalls resolves the upstreams the service calls to the ports the
+// sidecar listens on for them
+func (c *ConsulMeshService) serviceCalls() []ServiceCall {
+	calls := []ServiceCall{}
+	for _, upstream := range c.Calls {
+		calls = append(calls, ServiceCall{
+			Name:     upstream.Name,
+			Protocol: upstream.Protocol,
+			Port:     c.tracker.namedPorts[upstream.Name],
 		})
-	}, commands.SidecarArgs(
-		c.locality.getAddress(),
-		c.ID,
-		c.adminPort,
-	))
+	}
+	return calls
 }
 
 func (c *ConsulMeshService) renderService() error {
@@ -220,8 +407,12 @@ func (c *ConsulMeshService) executeTemplate(name string) ([]byte, error) {
 		Name:              c.Name,
 		Protocol:          c.Protocol,
 		ServicePort:       c.servicePort,
+		LocalServicePort:  c.localServicePort(),
 		ProxyPort:         c.proxyPort,
 		ExternalUpstreams: c.ExternalUpstreams,
+		MeshUpstreams:     c.meshUpstreams(),
+		Locality:          c.Locality,
+		Group:             c.Group,
 	}); err != nil {
 		return nil, err
 	}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 
+	"github.com/andrewstucki/consul-services/pkg/commands"
 	"github.com/andrewstucki/consul-services/pkg/server"
 )
 
@@ -14,9 +15,15 @@ type ConsulMeshGateway struct {
 	// Server is used for service registration
 	Server *server.Server
 
+	// WANFederation exposes the Consul servers of the datacenter through the gateway
+	// so that datacenters can federate through it
+	WANFederation bool
+
 	// adminPort is the port allocated for envoy's admin interface
 	adminPort int
-	// proxyPort is the port allocated for envoy's proxy interface
+	// proxyPort is the port allocated for envoy's proxy interface, it's
+	// allocated ahead of time for the primary datacenter when federating
+	// through mesh gateways
 	proxyPort int
 
 	// locality identifies the datacenter/partition/namespace a service is deployed in
@@ -38,12 +45,16 @@ func (c *ConsulMeshGateway) allocatePorts() error {
 		return err
 	}
 
+	c.adminPort = adminPort
+	if c.proxyPort != 0 {
+		return nil
+	}
+
 	proxyPort, err := freePort()
 	if err != nil {
 		return err
 	}
 
-	c.adminPort = adminPort
 	c.proxyPort = proxyPort
 	return nil
 }
@@ -53,20 +64,23 @@ func (c *ConsulMeshGateway) runEnvoy(ctx context.Context) error {
 
 	return c.runConsulBinary(ctx, func(log string) {
 		c.Server.Register(server.Service{
-			Datacenter: c.locality.Datacenter,
-			Partition:  c.locality.Partition,
-			Namespace:  c.locality.Namespace,
-			Kind:       "mesh",
-			Name:       "mesh-" + c.locality.Datacenter,
-			AdminPort:  c.adminPort,
-			Ports:      []int{c.proxyPort},
-			Logs:       log,
+			Datacenter:     c.locality.Datacenter,
+			Partition:      c.locality.Partition,
+			Namespace:      c.locality.Namespace,
+			Kind:           "mesh",
+			Name:           "mesh-" + c.locality.Datacenter,
+			AdminPort:      c.adminPort,
+			Ports:          []int{c.proxyPort},
+			Logs:           log,
+			ConsulAddress:  c.locality.getAddress(),
+			RegisteredPort: c.proxyPort,
+			ExposeServers:  c.WANFederation,
 		})
 	}, c.gatewayArgs())
 }
 
 func (c *ConsulMeshGateway) gatewayArgs() []string {
-	return []string{
+	args := []string{
 		"connect", "envoy",
 		"-gateway", "mesh",
 		"-register",
@@ -75,4 +89,8 @@ func (c *ConsulMeshGateway) gatewayArgs() []string {
 		"-address", fmt.Sprintf("127.0.0.1:%d", c.proxyPort),
 		"--", "-l", "trace",
 	}
+	if c.WANFederation {
+		args = commands.WithExposedServers(args)
+	}
+	return args
 }
@@ -0,0 +1,207 @@
+package pkg
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"path"
+
+	"github.com/andrewstucki/consul-services/pkg/commands"
+	"github.com/andrewstucki/consul-services/pkg/native"
+	"github.com/andrewstucki/consul-services/pkg/server"
+	"github.com/andrewstucki/consul-services/pkg/vfs"
+	"golang.org/x/sync/errgroup"
+)
+
+const nativeServiceKind = "native"
+
+// ConsulNativeService is a connect-native service run on the Consul service mesh, rather than
+// using a sidecar it gets its certificates from Consul and serves mTLS itself.
+type ConsulNativeService struct {
+	*ConsulCommand
+
+	// ID is the id of the service to run
+	ID string
+	// Name is the name of the service to run
+	Name string
+	// OnRegister is a channel to write back to when we've registered our services
+	OnRegister chan struct{}
+	// Server is used for service registration
+	Server *server.Server
+	// Upstreams are the protocols of the services that can be called
+	// natively from the service, keyed by name
+	Upstreams map[string]string
+	// Locality is the region and zone the instance runs in
+	Locality serviceLocality
+	// Group is the instance group the instance belongs to, if any
+	Group *InstanceGroup
+
+	// servicePort is the port allocated for the service
+	servicePort int
+	// tracker holds any dynamic allocations
+	tracker *tracker
+	// requests records the traffic received by the service
+	requests *server.RequestLog
+	// gate lets the service be killed or paused
+	gate *instanceGate
+
+	// locality identifies the datacenter/partition/namespace a service is deployed in
+	locality locality
+}
+
+// Run runs the Consul connect-native service
+func (c *ConsulNativeService) Run(ctx context.Context) error {
+	var err error
+
+	c.servicePort, err = freePort()
+	if err != nil {
+		return err
+	}
+
+	if err := c.renderServiceDefaults(); err != nil {
+		return err
+	}
+	if err := c.renderService(); err != nil {
+		return err
+	}
+
+	if err := c.registerService(ctx); err != nil {
+		return err
+	}
+	if err := c.writeServiceDefaults(ctx); err != nil {
+		return err
+	}
+
+	client, err := c.locality.getClient()
+	if err != nil {
+		return err
+	}
+	source := native.NewSource(client, c.Name, c.locality.Partition, c.locality.Namespace)
+	if err := source.Fetch(ctx); err != nil {
+		return err
+	}
+
+	logger, logFile, err := c.createServiceLogger(c.ID)
+	if err != nil {
+		return err
+	}
+	defer logFile.Close()
+
+	c.requests = server.NewRequestLog(defaultRequestLogSize)
+	c.gate = newInstanceGate()
+
+	c.OnRegister <- struct{}{}
+	c.Server.Register(server.Service{
+		Datacenter:              c.locality.Datacenter,
+		Partition:               c.locality.Partition,
+		Namespace:               c.locality.Namespace,
+		Kind:                    nativeServiceKind,
+		Name:                    c.ID,
+		Zone:                    c.Locality.Zone,
+		Group:                   c.groupName(),
+		Ports:                   []int{c.servicePort},
+		Upstreams:               c.Upstreams,
+		ServiceDefaultsFile:     c.serviceDefaultsFile(),
+		ServiceRegistrationFile: c.serviceFile(),
+		ConsulAddress:           c.locality.getAddress(),
+		Protocol:                protocolHTTP,
+		ServicePort:             c.servicePort,
+		ConsulService:           c.Name,
+		Logs:                    logFile.Name(),
+		Requests:                c.requests,
+		Lifecycle:               c.gate,
+	})
+
+	c.Logger.Info("running connect-native service", "service", c.servicePort)
+
+	group, ctx := errgroup.WithContext(ctx)
+	group.Go(func() error {
+		return source.Watch(ctx)
+	})
+	group.Go(func() error {
+		service := &Service{
+			ID:         c.ID,
+			Datacenter: c.locality.Datacenter,
+			Protocol:   protocolHTTP,
+			Port:       c.servicePort,
+			Logger:     logger,
+			Requests:   c.requests,
+			Group:      c.Group,
+			TLS:        source.ServerTLSConfig(),
+			gate:       c.gate,
+		}
+		return service.Run(ctx)
+	})
+
+	return group.Wait()
+}
+
+func (c *ConsulNativeService) groupName() string {
+	if c.Group == nil {
+		return ""
+	}
+	return c.Group.Name
+}
+
+func (c *ConsulNativeService) registerService(ctx context.Context) error {
+	c.Logger.Info("registering service", "id", c.ID)
+
+	return c.runConsulBinary(ctx, nil, commands.RegisterServiceArgs(
+		c.locality.Datacenter,
+		c.locality.getAddress(),
+		vfs.PathFor(c.serviceFile()),
+	))
+}
+
+func (c *ConsulNativeService) writeServiceDefaults(ctx context.Context) error {
+	c.Logger.Info("writing service defaults", "id", c.ID)
+
+	return c.runConsulBinary(ctx, nil, commands.WriteConfigArgs(
+		c.locality.Datacenter,
+		c.locality.getAddress(),
+		vfs.PathFor(c.serviceDefaultsFile()),
+	))
+}
+
+func (c *ConsulNativeService) renderService() error {
+	return c.renderTemplate(serviceTemplate, c.serviceFile())
+}
+
+func (c *ConsulNativeService) renderServiceDefaults() error {
+	return c.renderTemplate(serviceDefaultsTemplate, c.serviceDefaultsFile())
+}
+
+func (c *ConsulNativeService) renderTemplate(template, name string) error {
+	rendered, err := c.executeTemplate(template)
+	if err != nil {
+		return err
+	}
+	return vfs.WriteFile(name, rendered, 0600)
+}
+
+func (c *ConsulNativeService) serviceFile() string {
+	return path.Join(c.locality.Datacenter, fmt.Sprintf("native-service-%s.hcl", c.ID))
+}
+
+func (c *ConsulNativeService) serviceDefaultsFile() string {
+	return path.Join(c.locality.Datacenter, fmt.Sprintf("native-service-defaults-%s.hcl", c.ID))
+}
+
+func (c *ConsulNativeService) executeTemplate(name string) ([]byte, error) {
+	var buffer bytes.Buffer
+
+	if err := getTemplate(name).Execute(&buffer, &templateArgs{
+		tracker:     c.tracker,
+		ID:          c.ID,
+		Name:        c.Name,
+		Protocol:    protocolHTTP,
+		ServicePort: c.servicePort,
+		Locality:    c.Locality,
+		Group:       c.Group,
+		Native:      true,
+	}); err != nil {
+		return nil, err
+	}
+
+	return buffer.Bytes(), nil
+}
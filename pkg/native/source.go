@@ -0,0 +1,293 @@
+package native
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/consul/api"
+	"golang.org/x/sync/errgroup"
+)
+
+const (
+	minRetryWait = 500 * time.Millisecond
+	maxRetryWait = 30 * time.Second
+)
+
+// Source fetches the leaf certificate of a connect-native service along with
+// the roots of the mesh from Consul, keeping them up to date so that the
+// service can serve and dial mTLS connections the same way the connect SDK does.
+//
+// The connect SDK lives in the main Consul module rather than its api module,
+// depending on it would pull all of Consul's server dependencies into this tool,
+// so this implements the small part of it that native services need.
+type Source struct {
+	client  *api.Client
+	service string
+	options api.QueryOptions
+
+	leaf        *tls.Certificate
+	roots       *x509.CertPool
+	trustDomain string
+	mutex       sync.RWMutex
+}
+
+// NewSource creates a source for the given service, the partition and
+// namespace are optional.
+func NewSource(client *api.Client, service, partition, namespace string) *Source {
+	return &Source{
+		client:  client,
+		service: service,
+		options: api.QueryOptions{
+			Partition: partition,
+			Namespace: namespace,
+		},
+	}
+}
+
+// Fetch fetches the current leaf certificate and roots.
+func (s *Source) Fetch(ctx context.Context) error {
+	if _, err := s.fetchRoots(ctx, 0); err != nil {
+		return err
+	}
+	_, err := s.fetchLeaf(ctx, 0)
+	return err
+}
+
+// Watch keeps the leaf certificate and roots up to date until the context is canceled.
+func (s *Source) Watch(ctx context.Context) error {
+	group, ctx := errgroup.WithContext(ctx)
+	group.Go(func() error {
+		return watch(ctx, s.fetchRoots)
+	})
+	group.Go(func() error {
+		return watch(ctx, s.fetchLeaf)
+	})
+	return group.Wait()
+}
+
+func watch(ctx context.Context, fetch func(ctx context.Context, index uint64) (uint64, error)) error {
+	var index uint64
+	wait := minRetryWait
+	for {
+		next, err := fetch(ctx, index)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			// the agent may be restarting or the query may have timed out, so
+			// back off and retry rather than taking the service down with it
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-time.After(wait):
+			}
+			wait *= 2
+			if wait > maxRetryWait {
+				wait = maxRetryWait
+			}
+			continue
+		}
+		wait = minRetryWait
+
+		// an index going backwards means the agent's state was reset, so start over
+		if next < index {
+			next = 0
+		}
+		index = next
+	}
+}
+
+func (s *Source) fetchRoots(ctx context.Context, index uint64) (uint64, error) {
+	options := s.options
+	options.WaitIndex = index
+
+	roots, meta, err := s.client.Agent().ConnectCARoots(options.WithContext(ctx))
+	if err != nil {
+		return 0, err
+	}
+
+	pool := x509.NewCertPool()
+	for _, root := range roots.Roots {
+		pool.AppendCertsFromPEM([]byte(root.RootCertPEM))
+	}
+
+	s.mutex.Lock()
+	s.roots = pool
+	s.trustDomain = roots.TrustDomain
+	s.mutex.Unlock()
+
+	return meta.LastIndex, nil
+}
+
+func (s *Source) fetchLeaf(ctx context.Context, index uint64) (uint64, error) {
+	options := s.options
+	options.WaitIndex = index
+
+	leaf, meta, err := s.client.Agent().ConnectCALeaf(s.service, options.WithContext(ctx))
+	if err != nil {
+		return 0, err
+	}
+
+	certificate, err := tls.X509KeyPair([]byte(leaf.CertPEM), []byte(leaf.PrivateKeyPEM))
+	if err != nil {
+		return 0, err
+	}
+
+	s.mutex.Lock()
+	s.leaf = &certificate
+	s.mutex.Unlock()
+
+	return meta.LastIndex, nil
+}
+
+func (s *Source) current() (*tls.Certificate, *x509.CertPool) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return s.leaf, s.roots
+}
+
+func (s *Source) currentTrustDomain() string {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return s.trustDomain
+}
+
+// ServerTLSConfig returns the TLS configuration for serving mTLS to the rest of
+// the mesh, connections are authorized against intentions by the Consul agent.
+func (s *Source) ServerTLSConfig() *tls.Config {
+	return &tls.Config{
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			leaf, roots := s.current()
+			if leaf == nil {
+				return nil, errors.New("no leaf certificate")
+			}
+
+			return &tls.Config{
+				Certificates:     []tls.Certificate{*leaf},
+				ClientCAs:        roots,
+				ClientAuth:       tls.RequireAndVerifyClientCert,
+				VerifyConnection: s.authorize,
+			}, nil
+		},
+	}
+}
+
+// authorize checks the intentions for the identity of the client
+func (s *Source) authorize(state tls.ConnectionState) error {
+	if len(state.PeerCertificates) == 0 || len(state.PeerCertificates[0].URIs) == 0 {
+		return errors.New("client certificate has no identity")
+	}
+
+	client := state.PeerCertificates[0]
+	authorization, err := s.client.Agent().ConnectAuthorize(&api.AgentAuthorizeParams{
+		Target:           s.service,
+		ClientCertURI:    client.URIs[0].String(),
+		ClientCertSerial: serialString(client),
+	})
+	if err != nil {
+		return err
+	}
+	if !authorization.Authorized {
+		return fmt.Errorf("unauthorized: %s", authorization.Reason)
+	}
+	return nil
+}
+
+// ClientTLSConfig returns the TLS configuration for dialing the given service,
+// the server must present a certificate for the service issued by the mesh.
+func (s *Source) ClientTLSConfig(target string) *tls.Config {
+	return &tls.Config{
+		GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			leaf, _ := s.current()
+			if leaf == nil {
+				return nil, errors.New("no leaf certificate")
+			}
+			return leaf, nil
+		},
+		// mesh certificates identify services by URI rather than host name, so
+		// the chain and identity are verified below instead
+		InsecureSkipVerify: true,
+		VerifyPeerCertificate: func(rawCertificates [][]byte, _ [][]*x509.Certificate) error {
+			_, roots := s.current()
+			return verifyServer(rawCertificates, roots, s.currentTrustDomain(), target)
+		},
+	}
+}
+
+func verifyServer(rawCertificates [][]byte, roots *x509.CertPool, trustDomain, target string) error {
+	certificates := []*x509.Certificate{}
+	for _, raw := range rawCertificates {
+		certificate, err := x509.ParseCertificate(raw)
+		if err != nil {
+			return err
+		}
+		certificates = append(certificates, certificate)
+	}
+	if len(certificates) == 0 {
+		return errors.New("server presented no certificates")
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, certificate := range certificates[1:] {
+		intermediates.AddCert(certificate)
+	}
+
+	server := certificates[0]
+	if _, err := server.Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+	}); err != nil {
+		return err
+	}
+
+	// identities look like spiffe://<trust domain>/ns/<namespace>/dc/<dc>/svc/<service>
+	for _, uri := range server.URIs {
+		if uri.Scheme == "spiffe" && strings.EqualFold(uri.Host, trustDomain) && strings.HasSuffix(uri.Path, "/svc/"+target) {
+			return nil
+		}
+	}
+	return fmt.Errorf("server certificate does not identify service %q in trust domain %q", target, trustDomain)
+}
+
+// Resolve returns the address of a healthy instance of the given service, or
+// the sidecar proxy in front of it, in the given datacenter.
+func (s *Source) Resolve(ctx context.Context, target, datacenter string) (string, error) {
+	options := s.options
+	options.Datacenter = datacenter
+
+	entries, _, err := s.client.Health().Connect(target, "", true, options.WithContext(ctx))
+	if err != nil {
+		return "", err
+	}
+	if len(entries) == 0 {
+		return "", fmt.Errorf("no healthy instances of %q", target)
+	}
+
+	entry := entries[rand.Intn(len(entries))]
+	address := entry.Service.Address
+	if address == "" {
+		address = entry.Node.Address
+	}
+	return net.JoinHostPort(address, strconv.Itoa(entry.Service.Port)), nil
+}
+
+// serialString formats a certificate serial number the way Consul does
+func serialString(certificate *x509.Certificate) string {
+	serial := certificate.SerialNumber.Bytes()
+	parts := make([]string, 0, len(serial))
+	for _, b := range serial {
+		parts = append(parts, fmt.Sprintf("%02x", b))
+	}
+	return strings.Join(parts, ":")
+}
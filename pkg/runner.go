@@ -37,10 +37,29 @@ func (r *Runner) Run(ctx context.Context) error {
 	// we want to register all of our services
 	// with the control server so we can return
 	// information about them
+	rootCA, err := r.certificateAuthority()
+	if err != nil {
+		return err
+	}
+
 	controlServer := server.New(r.config.Logger, r.config.Socket, r.config.Datacenters)
+	controlServer.CertificateAuthority = rootCA.Certificate
+	controlServer.CertificateAuthorityKey = rootCA.PrivateKey
+
+	// the identity provider is only started once a resource file
+	// or a minted token needs it
+	controlServer.JWT = lazyJWTMinter{}
+	controlServer.Processes = r.config.consulCommand
+	if r.config.ExtAuthz {
+		controlServer.Authz = server.NewAuthzPolicyStore()
+	}
+
 	group.Go(func() error {
 		return controlServer.Run(ctx)
 	})
+	group.Go(func() error {
+		return runJWTProvider(ctx)
+	})
 
 	agents := []*ConsulAgent{}
 	addresses := []string{}
@@ -48,22 +67,44 @@ func (r *Runner) Run(ctx context.Context) error {
 	meshGatewayServices := []*ConsulMeshGateway{}
 	externalServices := []*ConsulExternalService{}
 	meshServices := []*ConsulMeshService{}
+	nativeServices := []*ConsulNativeService{}
+	var extAuthzService *ConsulExtAuthzService
 	resources := []interface{}{}
 
-	for _, dc := range r.config.Datacenters {
+	// secondary datacenters federating through mesh gateways need the
+	// address of the primary's gateway before its agent starts
+	primaryGatewayPort := 0
+	if r.config.WANFederation {
+		primaryGatewayPort, err = freePort()
+		if err != nil {
+			return err
+		}
+	}
+
+	var primary locality
+	for i, dc := range r.config.Datacenters {
 		locale := locality{
 			Datacenter: dc,
 			// Add namespace/partition support later
 		}
 
 		if r.config.RunConsul {
+			// there's a single agent per datacenter, so it's placed
+			// in the first zone along with the first duplicate of each
+			// service, gateways are registered through the agent with
+			// `consul connect envoy -register` and inherit its locality
 			consul := &ConsulAgent{
 				ConsulCommand:     r.config.consulCommand,
 				Server:            controlServer,
 				Datacenter:        dc,
 				PrimaryDatacenter: r.config.Datacenters[0],
+				Locality:          r.config.zoneFor(1),
+				WANFederation:     r.config.WANFederation,
 				tracker:           newTracker(),
 			}
+			if r.config.WANFederation && i > 0 {
+				consul.PrimaryGateway = fmt.Sprintf("127.0.0.1:%d", primaryGatewayPort)
+			}
 
 			if err := consul.Write(); err != nil {
 				return err
@@ -91,21 +132,56 @@ func (r *Runner) Run(ctx context.Context) error {
 			}
 			locale.client = client
 			locale.address = consul.address()
+			locale.grpcPort = consul.tracker.namedPorts["grpc"]
 		}
 
 		// register mesh gateway
-		meshGatewayServices = append(meshGatewayServices, &ConsulMeshGateway{
+		meshGateway := &ConsulMeshGateway{
 			ConsulCommand: r.config.consulCommand,
 			Server:        controlServer,
+			WANFederation: r.config.WANFederation,
 			locality:      locale,
-		})
+		}
+		if i == 0 {
+			primary = locale
+			meshGateway.proxyPort = primaryGatewayPort
+		}
+		meshGatewayServices = append(meshGatewayServices, meshGateway)
 
 		upstreams, external := r.initializeExternalServices(locale, controlServer)
 		externalServices = append(externalServices, external...)
 
+		if r.config.TerminatingGateway && len(upstreams) > 0 {
+			gateway, err := newTerminatingGateway(r.config.consulCommand, controlServer, locale, upstreams, r.config.TerminatingGatewayTLS)
+			if err != nil {
+				return err
+			}
+			resources = append(resources, gateway)
+		}
+
 		services := r.initializeMeshServices(locale, controlServer, upstreams)
 		meshServices = append(meshServices, services...)
 
+		natives := r.initializeNativeServices(locale, controlServer)
+		nativeServices = append(nativeServices, natives...)
+
+		if r.config.ExtAuthz && extAuthzService == nil {
+			// a single ext_authz service in the primary datacenter is enough
+			// since extensions reference it by its loopback address
+			extAuthzService, err = newConsulExtAuthzService(r.config.consulCommand, controlServer, r.registrationCh, locale)
+			if err != nil {
+				return err
+			}
+		}
+
+		for _, gateway := range r.config.APIGateways {
+			generated, err := r.config.newAPIGatewayResources(controlServer, locale, gateway)
+			if err != nil {
+				return err
+			}
+			resources = append(resources, generated...)
+		}
+
 		if r.config.ResourceFolder != "" {
 			folder := r.config.ResourceFolder
 			if len(r.config.Datacenters) > 1 {
@@ -144,9 +220,19 @@ func (r *Runner) Run(ctx context.Context) error {
 		}
 	}
 
+	if len(r.config.Datacenters) > 1 {
+		defaults, err := r.config.newMeshDefaults(controlServer, primary, resources)
+		if err != nil {
+			return err
+		}
+		resources = append(defaults, resources...)
+	}
+
 	// reverse the order of the join so that the first
-	// listed DC winds up being the primary
-	for i := len(agents) - 1; i >= 0; i-- {
+	// listed DC winds up being the primary, datacenters
+	// federating through mesh gateways find the primary
+	// through its gateway instead
+	for i := len(agents) - 1; i >= 0 && !r.config.WANFederation; i-- {
 		agent := agents[i]
 		if err := agent.join(ctx, addresses); err != nil {
 			select {
@@ -183,6 +269,21 @@ func (r *Runner) Run(ctx context.Context) error {
 	}
 	r.waitForNRegistrations(ctx, len(meshServices))
 
+	for i := range nativeServices {
+		service := nativeServices[i]
+		group.Go(func() error {
+			return service.Run(ctx)
+		})
+	}
+	r.waitForNRegistrations(ctx, len(nativeServices))
+
+	if extAuthzService != nil {
+		group.Go(func() error {
+			return extAuthzService.Run(ctx)
+		})
+		r.waitForNRegistrations(ctx, 1)
+	}
+
 	for _, entry := range resources {
 		switch e := entry.(type) {
 		case *ConsulConfigEntry:
@@ -194,6 +295,12 @@ func (r *Runner) Run(ctx context.Context) error {
 					return err
 				}
 			}
+
+			if e.rotationInterval() > 0 {
+				group.Go(func() error {
+					return e.Rotate(ctx)
+				})
+			}
 		case *ConsulGateway:
 			e.Server = controlServer
 
@@ -206,6 +313,15 @@ func (r *Runner) Run(ctx context.Context) error {
 	return group.Wait()
 }
 
+// certificateAuthority loads the root CA from the configured directory, generating
+// and persisting one there if needed, or generates one that lives only for this run
+func (r *Runner) certificateAuthority() (*CertificateInfo, error) {
+	if r.config.CertificateAuthorityDirectory != "" {
+		return LoadCertificateAuthority(r.config.CertificateAuthorityDirectory)
+	}
+	return CertificateAuthority()
+}
+
 func (r *Runner) waitForNRegistrations(ctx context.Context, n int) {
 	if n <= 0 {
 		return
@@ -225,76 +341,85 @@ func (r *Runner) waitForNRegistrations(ctx context.Context, n int) {
 	}
 }
 
-func (r *Runner) initializeExternalServices(locality locality, server *server.Server) ([]string, []*ConsulExternalService) {
-	upstreams := []string{}
+func (r *Runner) initializeExternalServices(locality locality, server *server.Server) ([]upstream, []*ConsulExternalService) {
+	upstreams := []upstream{}
 	services := []*ConsulExternalService{}
 
-	for i := 1; i <= r.config.ExternalHTTPServiceCount; i++ {
-		upstreams = append(upstreams, httpExternalServiceName(i))
-		for j := 1; j <= r.config.ServiceDuplicates; j++ {
-			services = append(services, &ConsulExternalService{
-				ConsulCommand: r.config.consulCommand,
-				ID:            httpExternalServiceID(locality, i, j),
-				Name:          httpExternalServiceName(i),
-				Protocol:      protocolHTTP,
-				OnRegister:    r.registrationCh,
-				Server:        server,
-				tracker:       newTracker(),
-				locality:      locality,
-			})
-		}
-	}
-
-	for i := 1; i <= r.config.ExternalTCPServiceCount; i++ {
-		upstreams = append(upstreams, tcpExternalServiceName(i))
-		for j := 1; j <= r.config.ServiceDuplicates; j++ {
-			services = append(services, &ConsulExternalService{
-				ConsulCommand: r.config.consulCommand,
-				ID:            tcpExternalServiceID(locality, i, j),
-				Name:          tcpExternalServiceName(i),
-				Protocol:      protocolTCP,
-				OnRegister:    r.registrationCh,
-				Server:        server,
-				tracker:       newTracker(),
-				locality:      locality,
+	for _, protocol := range r.config.externalServiceCounts() {
+		for i := 1; i <= protocol.count; i++ {
+			upstreams = append(upstreams, upstream{
+				Name:     externalServiceName(protocol.protocol, i),
+				Protocol: protocol.protocol,
 			})
+			for j := 1; j <= r.config.ServiceDuplicates; j++ {
+				services = append(services, &ConsulExternalService{
+					ConsulCommand: r.config.consulCommand,
+					ID:            externalServiceID(protocol.protocol, locality, i, j),
+					Name:          externalServiceName(protocol.protocol, i),
+					Protocol:      protocol.protocol,
+					OnRegister:    r.registrationCh,
+					Server:        server,
+					TLS:           r.config.TerminatingGatewayTLS,
+					Locality:      r.config.zoneFor(j),
+					tracker:       newTracker(),
+					locality:      locality,
+				})
+			}
 		}
 	}
 
 	return upstreams, services
 }
 
-func (r *Runner) initializeMeshServices(locality locality, server *server.Server, upstreams []string) []*ConsulMeshService {
+func (r *Runner) initializeMeshServices(locality locality, server *server.Server, upstreams []upstream) []*ConsulMeshService {
 	services := []*ConsulMeshService{}
 
-	for i := 1; i <= r.config.HTTPServiceCount; i++ {
-		for j := 1; j <= r.config.ServiceDuplicates; j++ {
-			services = append(services, &ConsulMeshService{
-				ConsulCommand:     r.config.consulCommand,
-				ID:                httpServiceID(locality, i, j),
-				Name:              httpServiceName(i),
-				Protocol:          protocolHTTP,
-				OnRegister:        r.registrationCh,
-				Server:            server,
-				ExternalUpstreams: upstreams,
-				tracker:           newTracker(),
-				locality:          locality,
-			})
+	for _, protocol := range r.config.meshServiceCounts() {
+		for i := 1; i <= protocol.count; i++ {
+			for j := 1; j <= r.config.ServiceDuplicates; j++ {
+				services = append(services, &ConsulMeshService{
+					ConsulCommand:     r.config.consulCommand,
+					ID:                serviceID(protocol.protocol, locality, i, j),
+					Name:              serviceName(protocol.protocol, i),
+					Protocol:          protocol.protocol,
+					OnRegister:        r.registrationCh,
+					Server:            server,
+					ExternalUpstreams: upstreams,
+					Calls:             r.config.callsFor(serviceName(protocol.protocol, i)),
+					Sidecar:           r.config.sidecarFor(serviceName(protocol.protocol, i)),
+					Locality:          r.config.zoneFor(j),
+					Group:             r.config.groupFor(j),
+					Chaos:             r.config.Chaos,
+					tracker:           newTracker(),
+					locality:          locality,
+				})
+			}
 		}
 	}
 
-	for i := 1; i <= r.config.TCPServiceCount; i++ {
+	return services
+}
+
+func (r *Runner) initializeNativeServices(locality locality, server *server.Server) []*ConsulNativeService {
+	services := []*ConsulNativeService{}
+
+	for i := 1; i <= r.config.NativeServiceCount; i++ {
+		// connect-native services can call anything on the mesh other than themselves
+		upstreams := r.config.meshServiceProtocols()
+		delete(upstreams, nativeServiceName(i))
+
 		for j := 1; j <= r.config.ServiceDuplicates; j++ {
-			services = append(services, &ConsulMeshService{
-				ConsulCommand:     r.config.consulCommand,
-				ID:                tcpServiceID(locality, i, j),
-				Name:              tcpServiceName(i),
-				Protocol:          protocolTCP,
-				OnRegister:        r.registrationCh,
-				Server:            server,
-				ExternalUpstreams: upstreams,
-				tracker:           newTracker(),
-				locality:          locality,
+			services = append(services, &ConsulNativeService{
+				ConsulCommand: r.config.consulCommand,
+				ID:            serviceID(nativeServiceKind, locality, i, j),
+				Name:          nativeServiceName(i),
+				OnRegister:    r.registrationCh,
+				Server:        server,
+				Upstreams:     upstreams,
+				Locality:      r.config.zoneFor(j),
+				Group:         r.config.groupFor(j),
+				tracker:       newTracker(),
+				locality:      locality,
 			})
 		}
 	}
@@ -302,36 +427,24 @@ func (r *Runner) initializeMeshServices(locality locality, server *server.Server
 	return services
 }
 
-func httpServiceID(locality locality, i, j int) string {
-	return fmt.Sprintf("http-%s-%d-%d", localitySuffix(locality), i, j)
-}
-
-func httpServiceName(i int) string {
-	return fmt.Sprintf("http-%d", i)
-}
-
-func httpExternalServiceID(locality locality, i, j int) string {
-	return fmt.Sprintf("http-external-%s-%d-%d", localitySuffix(locality), i, j)
-}
-
-func httpExternalServiceName(i int) string {
-	return fmt.Sprintf("http-external-%d", i)
+func serviceID(protocol string, locality locality, i, j int) string {
+	return fmt.Sprintf("%s-%s-%d-%d", protocol, localitySuffix(locality), i, j)
 }
 
-func tcpServiceID(locality locality, i, j int) string {
-	return fmt.Sprintf("tcp-%s-%d-%d", localitySuffix(locality), i, j)
+func serviceName(protocol string, i int) string {
+	return fmt.Sprintf("%s-%d", protocol, i)
 }
 
-func tcpServiceName(i int) string {
-	return fmt.Sprintf("tcp-%d", i)
+func nativeServiceName(i int) string {
+	return serviceName(nativeServiceKind, i)
 }
 
-func tcpExternalServiceID(locality locality, i, j int) string {
-	return fmt.Sprintf("tcp-external-%s-%d-%d", localitySuffix(locality), i, j)
+func externalServiceID(protocol string, locality locality, i, j int) string {
+	return fmt.Sprintf("%s-external-%s-%d-%d", protocol, localitySuffix(locality), i, j)
 }
 
-func tcpExternalServiceName(i int) string {
-	return fmt.Sprintf("tcp-external-%d", i)
+func externalServiceName(protocol string, i int) string {
+	return fmt.Sprintf("%s-external-%d", protocol, i)
 }
 
 func localitySuffix(locality locality) string {
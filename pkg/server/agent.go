@@ -3,10 +3,14 @@ package server
 // Consul contains information about registered Consul instances
 type Consul struct {
 	Datacenter string
+	// whether the instance is in the primary datacenter
+	Primary    bool `json:",omitempty"`
 	Ports      []int
 	NamedPorts map[string]int
 	Logs       string
 	Config     string
 	Address    string `json:"-"`
 	WanAddress string `json:"-"`
+	// whether the agent federates through mesh gateways rather than a WAN join
+	Federated bool `json:"-"`
 }
@@ -0,0 +1,133 @@
+package server
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+const (
+	// AuthzAllow allows a request.
+	AuthzAllow = "allow"
+	// AuthzDeny denies a request.
+	AuthzDeny = "deny"
+)
+
+// AuthzRule matches requests checked by the ext_authz service. Empty fields
+// match everything.
+type AuthzRule struct {
+	Action     string
+	Method     string `json:",omitempty" yaml:",omitempty"`
+	PathPrefix string `json:",omitempty" yaml:"pathPrefix,omitempty"`
+	Host       string `json:",omitempty" yaml:",omitempty"`
+	// Header is of the form name=value, or just name to match on presence
+	Header string `json:",omitempty" yaml:",omitempty"`
+	// Source is the name of the calling service
+	Source string `json:",omitempty" yaml:",omitempty"`
+}
+
+// AuthzPolicy is the ordered set of rules used by the ext_authz service, the
+// first matching rule wins and Default is used when nothing matches.
+type AuthzPolicy struct {
+	Default string
+	Rules   []AuthzRule
+}
+
+// AuthzRequest is the information about a request that rules are matched against.
+type AuthzRequest struct {
+	Method  string
+	Path    string
+	Host    string
+	Headers map[string]string
+	Source  string
+}
+
+// Validate checks that the policy only uses known actions.
+func (p AuthzPolicy) Validate() error {
+	if err := validateAuthzAction(p.Default); err != nil {
+		return fmt.Errorf("default: %w", err)
+	}
+	for i, rule := range p.Rules {
+		if err := validateAuthzAction(rule.Action); err != nil {
+			return fmt.Errorf("rule %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// Evaluate returns the action for the given request and the index of the
+// rule that matched, -1 if the default was used.
+func (p AuthzPolicy) Evaluate(request AuthzRequest) (string, int) {
+	for i, rule := range p.Rules {
+		if rule.matches(request) {
+			return rule.Action, i
+		}
+	}
+	return p.Default, -1
+}
+
+func (r AuthzRule) matches(request AuthzRequest) bool {
+	if r.Method != "" && !strings.EqualFold(r.Method, request.Method) {
+		return false
+	}
+	if r.PathPrefix != "" && !strings.HasPrefix(request.Path, r.PathPrefix) {
+		return false
+	}
+	if r.Host != "" && !strings.EqualFold(r.Host, request.Host) {
+		return false
+	}
+	if r.Source != "" && r.Source != request.Source {
+		return false
+	}
+	if r.Header != "" {
+		name, value, hasValue := strings.Cut(r.Header, "=")
+		actual, ok := request.Headers[strings.ToLower(strings.TrimSpace(name))]
+		if !ok || (hasValue && actual != strings.TrimSpace(value)) {
+			return false
+		}
+	}
+	return true
+}
+
+func validateAuthzAction(action string) error {
+	switch action {
+	case AuthzAllow, AuthzDeny:
+		return nil
+	default:
+		return fmt.Errorf("invalid action %q, must be %q or %q", action, AuthzAllow, AuthzDeny)
+	}
+}
+
+// AuthzPolicyStore holds the current policy of the ext_authz service.
+type AuthzPolicyStore struct {
+	policy AuthzPolicy
+	mutex  sync.RWMutex
+}
+
+// NewAuthzPolicyStore returns a store that allows everything until a policy is set.
+func NewAuthzPolicyStore() *AuthzPolicyStore {
+	return &AuthzPolicyStore{
+		policy: AuthzPolicy{Default: AuthzAllow},
+	}
+}
+
+// Get returns the current policy.
+func (s *AuthzPolicyStore) Get() AuthzPolicy {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return s.policy
+}
+
+// Set replaces the current policy.
+func (s *AuthzPolicyStore) Set(policy AuthzPolicy) error {
+	if err := policy.Validate(); err != nil {
+		return err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.policy = policy
+	return nil
+}
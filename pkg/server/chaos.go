@@ -0,0 +1,70 @@
+package server
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ChaosSettings are the network faults injected between a service's sidecar
+// and the service itself.
+type ChaosSettings struct {
+	// Latency is added before forwarding each chunk of data in either direction
+	Latency time.Duration
+	// Jitter is a random amount of up to the given duration added to Latency
+	Jitter time.Duration
+	// Bandwidth limits each direction of a connection to the given KB/s, 0 is unlimited
+	Bandwidth int
+	// DropRate is the probability, between 0 and 1, that a new connection is reset
+	DropRate float64
+	// SlowClose delays closing the sidecar's connection once the service has closed its side
+	SlowClose time.Duration
+	// Blackhole accepts connections and discards everything without ever responding
+	Blackhole bool
+}
+
+// Validate checks that the settings are within range.
+func (s ChaosSettings) Validate() error {
+	if s.Latency < 0 || s.Jitter < 0 || s.SlowClose < 0 {
+		return errors.New("durations must not be negative")
+	}
+	if s.Bandwidth < 0 {
+		return errors.New("bandwidth must not be negative")
+	}
+	if s.DropRate < 0 || s.DropRate > 1 {
+		return errors.New("drop rate must be between 0 and 1")
+	}
+	return nil
+}
+
+// ChaosStore holds the current chaos settings of a service.
+type ChaosStore struct {
+	settings ChaosSettings
+	mutex    sync.RWMutex
+}
+
+// NewChaosStore returns a store that doesn't inject any faults until settings are set.
+func NewChaosStore() *ChaosStore {
+	return &ChaosStore{}
+}
+
+// Get returns the current settings.
+func (s *ChaosStore) Get() ChaosSettings {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return s.settings
+}
+
+// Set replaces the current settings.
+func (s *ChaosStore) Set(settings ChaosSettings) error {
+	if err := settings.Validate(); err != nil {
+		return err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.settings = settings
+	return nil
+}
@@ -1,6 +1,7 @@
 package server
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
@@ -86,6 +87,117 @@ func (c *Client) Get(kind, name string) (*Service, error) {
 	return service, nil
 }
 
+// GetRequests gets the requests recently received by a controlled service.
+func (c *Client) GetRequests(kind, name string) ([]Request, error) {
+	url, err := url.Parse(requestPath("/services/" + kind + "/" + name + "/requests"))
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := c.client.Get(url.String())
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		return nil, err
+	}
+	if response.StatusCode != 200 {
+		return nil, fmt.Errorf("code: %d, message: %q", response.StatusCode, string(body))
+	}
+
+	requests := []Request{}
+	if err := json.Unmarshal(body, &requests); err != nil {
+		return nil, err
+	}
+	return requests, nil
+}
+
+// GetChaos gets the chaos settings of a controlled service.
+func (c *Client) GetChaos(kind, name string) (*ChaosSettings, error) {
+	response, err := c.client.Get(requestPath("/services/" + kind + "/" + name + "/chaos"))
+	if err != nil {
+		return nil, err
+	}
+	return readChaosSettings(response)
+}
+
+// SetChaos replaces the chaos settings of a controlled service.
+func (c *Client) SetChaos(kind, name string, settings ChaosSettings) (*ChaosSettings, error) {
+	data, err := json.Marshal(settings)
+	if err != nil {
+		return nil, err
+	}
+
+	request, err := http.NewRequest(http.MethodPut, requestPath("/services/"+kind+"/"+name+"/chaos"), bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("content-type", "application/json")
+
+	response, err := c.client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	return readChaosSettings(response)
+}
+
+func readChaosSettings(response *http.Response) (*ChaosSettings, error) {
+	defer response.Body.Close()
+
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		return nil, err
+	}
+	if response.StatusCode != 200 {
+		return nil, fmt.Errorf("code: %d, message: %q", response.StatusCode, string(body))
+	}
+
+	settings := &ChaosSettings{}
+	if err := json.Unmarshal(body, settings); err != nil {
+		return nil, err
+	}
+	return settings, nil
+}
+
+// Signal kills, pauses or resumes a controlled service.
+func (c *Client) Signal(kind, name, action string) error {
+	return c.signal("/services/"+kind+"/"+name+"/signal", action)
+}
+
+// SignalConsul kills, pauses or resumes the consul instance of a datacenter.
+func (c *Client) SignalConsul(dc, action string) error {
+	return c.signal("/consul/"+dc+"/signal", action)
+}
+
+func (c *Client) signal(path, action string) error {
+	url, err := url.Parse(requestPath(path))
+	if err != nil {
+		return err
+	}
+
+	query := url.Query()
+	query.Set("action", action)
+	url.RawQuery = query.Encode()
+
+	response, err := c.client.Post(url.String(), "text/plain", nil)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		return err
+	}
+	if response.StatusCode != 200 {
+		return fmt.Errorf("code: %d, message: %q", response.StatusCode, string(body))
+	}
+	return nil
+}
+
 // GetConsul returns a controlled consul instance.
 func (c *Client) GetConsul(dc string) (*Consul, error) {
 	url, err := url.Parse(requestPath("/consul/" + dc))
@@ -114,6 +226,34 @@ func (c *Client) GetConsul(dc string) (*Consul, error) {
 	return consul, nil
 }
 
+// ListConsuls lists the controlled consul instances.
+func (c *Client) ListConsuls() ([]Consul, error) {
+	url, err := url.Parse(requestPath("/consul"))
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := c.client.Get(url.String())
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		return nil, err
+	}
+	if response.StatusCode != 200 {
+		return nil, fmt.Errorf("code: %d, message: %q", response.StatusCode, string(body))
+	}
+
+	consuls := []Consul{}
+	if err := json.Unmarshal(body, &consuls); err != nil {
+		return nil, err
+	}
+	return consuls, nil
+}
+
 // GetReport returns a formatted script for reports.
 func (c *Client) GetReport() (string, error) {
 	url, err := url.Parse(requestPath("/report"))
@@ -138,6 +278,118 @@ func (c *Client) GetReport() (string, error) {
 	return string(body), nil
 }
 
+// GetCertificateAuthority returns the PEM encoded certificate of the CA used for config entries.
+func (c *Client) GetCertificateAuthority() (string, error) {
+	return c.getCertificateAuthority("pem")
+}
+
+// GetCertificateAuthorityBundle returns the PEM encoded certificate and private key of the CA
+// used for config entries.
+func (c *Client) GetCertificateAuthorityBundle() (string, error) {
+	return c.getCertificateAuthority("bundle")
+}
+
+func (c *Client) getCertificateAuthority(format string) (string, error) {
+	url, err := url.Parse(requestPath("/ca"))
+	if err != nil {
+		return "", err
+	}
+
+	query := url.Query()
+	query.Set("format", format)
+	url.RawQuery = query.Encode()
+
+	response, err := c.client.Get(url.String())
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		return "", err
+	}
+	if response.StatusCode != 200 {
+		return "", fmt.Errorf("code: %d, message: %q", response.StatusCode, string(body))
+	}
+
+	return string(body), nil
+}
+
+// MintJWT returns a token signed by the local identity provider with the given claims.
+func (c *Client) MintJWT(claims map[string]interface{}, ttl string) (string, error) {
+	data, err := json.Marshal(MintRequest{
+		Claims: claims,
+		TTL:    ttl,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	response, err := c.client.Post(requestPath("/jwt"), "application/json", bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		return "", err
+	}
+	if response.StatusCode != 200 {
+		return "", fmt.Errorf("code: %d, message: %q", response.StatusCode, string(body))
+	}
+
+	return string(body), nil
+}
+
+// GetAuthzPolicy returns the current policy of the ext_authz service.
+func (c *Client) GetAuthzPolicy() (*AuthzPolicy, error) {
+	response, err := c.client.Get(requestPath("/authz"))
+	if err != nil {
+		return nil, err
+	}
+	return readAuthzPolicy(response)
+}
+
+// SetAuthzPolicy replaces the policy of the ext_authz service.
+func (c *Client) SetAuthzPolicy(policy AuthzPolicy) (*AuthzPolicy, error) {
+	data, err := json.Marshal(policy)
+	if err != nil {
+		return nil, err
+	}
+
+	request, err := http.NewRequest(http.MethodPut, requestPath("/authz"), bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("content-type", "application/json")
+
+	response, err := c.client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	return readAuthzPolicy(response)
+}
+
+func readAuthzPolicy(response *http.Response) (*AuthzPolicy, error) {
+	defer response.Body.Close()
+
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		return nil, err
+	}
+	if response.StatusCode != 200 {
+		return nil, fmt.Errorf("code: %d, message: %q", response.StatusCode, string(body))
+	}
+
+	policy := &AuthzPolicy{}
+	if err := json.Unmarshal(body, policy); err != nil {
+		return nil, err
+	}
+	return policy, nil
+}
+
 // List lists the controlled services.
 func (c *Client) List(kinds ...string) ([]Service, error) {
 	url, err := url.Parse(requestPath("/services"))
@@ -0,0 +1,14 @@
+package server
+
+import "time"
+
+// Minter signs tokens with arbitrary claims.
+type Minter interface {
+	Mint(claims map[string]interface{}, ttl time.Duration) (string, error)
+}
+
+// MintRequest is a request to mint a token.
+type MintRequest struct {
+	Claims map[string]interface{}
+	TTL    string
+}
@@ -0,0 +1,33 @@
+package server
+
+import "fmt"
+
+const (
+	// ActionKill kills an instance outright.
+	ActionKill = "kill"
+	// ActionPause freezes an instance without letting it clean up.
+	ActionPause = "pause"
+	// ActionResume unfreezes a paused instance.
+	ActionResume = "resume"
+)
+
+// Lifecycle controls a running instance for failure testing.
+type Lifecycle interface {
+	Signal(action string) error
+}
+
+// ProcessController controls the processes that were started for
+// instances, identified by the log file they write to.
+type ProcessController interface {
+	Signal(log, action string) error
+}
+
+// ValidateAction checks that the given action is one we know how to perform.
+func ValidateAction(action string) error {
+	switch action {
+	case ActionKill, ActionPause, ActionResume:
+		return nil
+	default:
+		return fmt.Errorf("invalid action %q, must be one of %q, %q or %q", action, ActionKill, ActionPause, ActionResume)
+	}
+}
@@ -0,0 +1,13 @@
+package server
+
+// Listener is a listener configured on a gateway.
+type Listener struct {
+	// Name is the name of the listener, for listeners without a name this is the port
+	Name     string
+	Port     int
+	Protocol string
+	// Hostnames are the hostnames that the listener accepts traffic for
+	Hostnames []string `json:",omitempty"`
+	// TLS is whether the listener terminates TLS
+	TLS bool
+}
@@ -0,0 +1,57 @@
+package server
+
+import (
+	"sync"
+	"time"
+)
+
+// Request is a record of traffic received by one of the test services.
+type Request struct {
+	Time          time.Time
+	Method        string
+	Path          string
+	Host          string
+	Headers       map[string][]string
+	Source        string
+	BytesReceived int64
+	BytesSent     int64
+}
+
+// RequestLog is a fixed-size ring buffer of the most recent requests
+// received by a service.
+type RequestLog struct {
+	requests []Request
+	next     int
+	full     bool
+	mutex    sync.RWMutex
+}
+
+// NewRequestLog returns a request log holding at most size requests.
+func NewRequestLog(size int) *RequestLog {
+	return &RequestLog{
+		requests: make([]Request, size),
+	}
+}
+
+// Record adds a request to the log, evicting the oldest request if the log is full.
+func (l *RequestLog) Record(request Request) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.requests[l.next] = request
+	l.next = (l.next + 1) % len(l.requests)
+	if l.next == 0 {
+		l.full = true
+	}
+}
+
+// All returns the logged requests, oldest first.
+func (l *RequestLog) All() []Request {
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+
+	if !l.full {
+		return append([]Request{}, l.requests[:l.next]...)
+	}
+	return append(append([]Request{}, l.requests[l.next:]...), l.requests[:l.next]...)
+}
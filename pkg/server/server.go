@@ -9,6 +9,7 @@ import (
 	"sort"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/hashicorp/consul/api"
@@ -26,6 +27,21 @@ type Server struct {
 	// Datacenters are the names of the datacenters this server tracks resources for.
 	Datacenters []string
 
+	// CertificateAuthority is the PEM encoded certificate of the CA used to issue
+	// certificates for config entries.
+	CertificateAuthority string
+	// CertificateAuthorityKey is the PEM encoded private key of the CA.
+	CertificateAuthorityKey string
+
+	// JWT mints tokens for testing jwt-provider config entries.
+	JWT Minter
+
+	// Authz holds the policy of the ext_authz service, if it's running.
+	Authz *AuthzPolicyStore
+
+	// Processes signals the processes started for services that aren't run in-process.
+	Processes ProcessController
+
 	// consuls contains the registered consul instances
 	consuls []Consul
 	// services contains the registered services
@@ -53,8 +69,18 @@ func (s *Server) Run(ctx context.Context) error {
 	router.HandleFunc("/shutdown", s.shutdown)
 	router.HandleFunc("/services", s.listServices)
 	router.HandleFunc("/services/{kind}/{name}", s.getService)
+	router.HandleFunc("/services/{kind}/{name}/requests", s.getServiceRequests)
+	router.HandleFunc("/services/{kind}/{name}/chaos", s.getServiceChaos).Methods(http.MethodGet)
+	router.HandleFunc("/services/{kind}/{name}/chaos", s.setServiceChaos).Methods(http.MethodPut)
+	router.HandleFunc("/consul", s.listConsuls)
 	router.HandleFunc("/consul/{dc}", s.getConsul)
+	router.HandleFunc("/consul/{dc}/signal", s.signalConsul).Methods(http.MethodPost)
+	router.HandleFunc("/services/{kind}/{name}/signal", s.signalService).Methods(http.MethodPost)
 	router.HandleFunc("/report", s.getReport)
+	router.HandleFunc("/ca", s.getCertificateAuthority)
+	router.HandleFunc("/jwt", s.mintJWT).Methods(http.MethodPost)
+	router.HandleFunc("/authz", s.getAuthzPolicy).Methods(http.MethodGet)
+	router.HandleFunc("/authz", s.setAuthzPolicy).Methods(http.MethodPut)
 
 	s.server = &http.Server{
 		Handler: router,
@@ -100,11 +126,23 @@ func (s *Server) AddConsul(consul Consul) {
 	s.consuls = append(s.consuls, consul)
 }
 
-// AddEntry adds the entry instance to the control server.
+// AddEntry adds the entry instance to the control server, replacing
+// any previously added entry with the same identity.
 func (s *Server) AddEntry(entry Entry) {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
+	for i, existing := range s.entries {
+		if existing.Datacenter == entry.Datacenter &&
+			existing.Partition == entry.Partition &&
+			existing.Namespace == entry.Namespace &&
+			existing.Kind == entry.Kind &&
+			existing.Name == entry.Name {
+			s.entries[i] = entry
+			return
+		}
+	}
+
 	s.entries = append(s.entries, entry)
 }
 
@@ -167,6 +205,109 @@ func (s *Server) getService(w http.ResponseWriter, r *http.Request) {
 	fmt.Fprintf(w, "not found")
 }
 
+func (s *Server) getServiceRequests(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+
+	kind := params["kind"]
+	name := params["name"]
+
+	encoder := json.NewEncoder(w)
+
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	for _, service := range s.services {
+		if kind == service.Kind && name == service.Name {
+			if service.Requests == nil {
+				w.WriteHeader(http.StatusBadRequest)
+				fmt.Fprintf(w, "service does not record requests")
+				return
+			}
+
+			w.Header().Set("content-type", "application/json")
+			encoder.Encode(service.Requests.All())
+			return
+		}
+	}
+
+	w.WriteHeader(http.StatusNotFound)
+	fmt.Fprintf(w, "not found")
+}
+
+func (s *Server) getServiceChaos(w http.ResponseWriter, r *http.Request) {
+	store, ok := s.chaosStore(w, r)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("content-type", "application/json")
+	json.NewEncoder(w).Encode(store.Get())
+}
+
+func (s *Server) setServiceChaos(w http.ResponseWriter, r *http.Request) {
+	store, ok := s.chaosStore(w, r)
+	if !ok {
+		return
+	}
+
+	settings := ChaosSettings{}
+	if err := json.NewDecoder(r.Body).Decode(&settings); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, "invalid settings: %v", err)
+		return
+	}
+	if err := store.Set(settings); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, "invalid settings: %v", err)
+		return
+	}
+
+	w.Header().Set("content-type", "application/json")
+	json.NewEncoder(w).Encode(settings)
+}
+
+// chaosStore looks up the chaos settings of the service in the request, writing
+// an error response if it can't be found
+func (s *Server) chaosStore(w http.ResponseWriter, r *http.Request) (*ChaosStore, bool) {
+	params := mux.Vars(r)
+
+	kind := params["kind"]
+	name := params["name"]
+
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	for _, service := range s.services {
+		if kind == service.Kind && name == service.Name {
+			if service.Chaos == nil {
+				w.WriteHeader(http.StatusBadRequest)
+				fmt.Fprintf(w, "service is not running behind a chaos proxy")
+				return nil, false
+			}
+			return service.Chaos, true
+		}
+	}
+
+	w.WriteHeader(http.StatusNotFound)
+	fmt.Fprintf(w, "not found")
+	return nil, false
+}
+
+func (s *Server) listConsuls(w http.ResponseWriter, r *http.Request) {
+	encoder := json.NewEncoder(w)
+
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	consuls := append([]Consul{}, s.consuls...)
+	sort.SliceStable(consuls, func(i, j int) bool {
+		return consuls[i].Datacenter < consuls[j].Datacenter
+	})
+
+	w.Header().Set("content-type", "application/json")
+	encoder.Encode(consuls)
+}
+
 func (s *Server) getConsul(w http.ResponseWriter, r *http.Request) {
 	params := mux.Vars(r)
 
@@ -189,6 +330,172 @@ func (s *Server) getConsul(w http.ResponseWriter, r *http.Request) {
 	fmt.Fprintf(w, "not found")
 }
 
+func (s *Server) signalService(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+
+	kind := params["kind"]
+	name := params["name"]
+	action := r.URL.Query().Get("action")
+
+	if err := ValidateAction(action); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, err.Error())
+		return
+	}
+
+	s.mutex.RLock()
+	var found *Service
+	for _, service := range s.services {
+		if kind == service.Kind && name == service.Name {
+			service := service
+			found = &service
+			break
+		}
+	}
+	s.mutex.RUnlock()
+
+	if found == nil {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprintf(w, "not found")
+		return
+	}
+
+	var err error
+	switch {
+	case found.Lifecycle != nil:
+		err = found.Lifecycle.Signal(action)
+	case s.Processes != nil:
+		err = s.Processes.Signal(found.Logs, action)
+	default:
+		err = fmt.Errorf("%s %q cannot be signaled", kind, name)
+	}
+	s.writeSignalResult(w, err)
+}
+
+func (s *Server) signalConsul(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+
+	datacenter := params["dc"]
+	action := r.URL.Query().Get("action")
+
+	if err := ValidateAction(action); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, err.Error())
+		return
+	}
+
+	s.mutex.RLock()
+	logs := ""
+	for _, consul := range s.consuls {
+		if datacenter == consul.Datacenter {
+			logs = consul.Logs
+			break
+		}
+	}
+	s.mutex.RUnlock()
+
+	if logs == "" || s.Processes == nil {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprintf(w, "not found")
+		return
+	}
+
+	s.writeSignalResult(w, s.Processes.Signal(logs, action))
+}
+
+func (s *Server) writeSignalResult(w http.ResponseWriter, err error) {
+	if err != nil {
+		w.WriteHeader(http.StatusConflict)
+		fmt.Fprint(w, err.Error())
+		return
+	}
+	fmt.Fprint(w, "ok")
+}
+
+// getCertificateAuthority serves the CA certificate, along with its private key for the
+// bundle format, so anything that can connect to the socket can get at the key
+func (s *Server) getCertificateAuthority(w http.ResponseWriter, r *http.Request) {
+	if s.CertificateAuthority == "" {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprintf(w, "not found")
+		return
+	}
+
+	w.Header().Set("content-type", "application/x-pem-file")
+	fmt.Fprint(w, s.CertificateAuthority)
+	if r.URL.Query().Get("format") == "bundle" {
+		fmt.Fprint(w, s.CertificateAuthorityKey)
+	}
+}
+
+func (s *Server) mintJWT(w http.ResponseWriter, r *http.Request) {
+	if s.JWT == nil {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprintf(w, "not found")
+		return
+	}
+
+	request := MintRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, "invalid request: %v", err)
+		return
+	}
+
+	ttl := time.Hour
+	if request.TTL != "" {
+		duration, err := time.ParseDuration(request.TTL)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(w, "invalid ttl: %v", err)
+			return
+		}
+		ttl = duration
+	}
+
+	token, err := s.JWT.Mint(request.Claims, ttl)
+	if err != nil {
+		s.Logger.Error("token minting error", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprint(w, "internal error")
+		return
+	}
+
+	fmt.Fprint(w, token)
+}
+
+func (s *Server) getAuthzPolicy(w http.ResponseWriter, r *http.Request) {
+	if s.Authz == nil {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprintf(w, "not found")
+		return
+	}
+
+	json.NewEncoder(w).Encode(s.Authz.Get())
+}
+
+func (s *Server) setAuthzPolicy(w http.ResponseWriter, r *http.Request) {
+	if s.Authz == nil {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprintf(w, "not found")
+		return
+	}
+
+	policy := AuthzPolicy{}
+	if err := json.NewDecoder(r.Body).Decode(&policy); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, "invalid policy: %v", err)
+		return
+	}
+	if err := s.Authz.Set(policy); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, "invalid policy: %v", err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(policy)
+}
+
 func (s *Server) getReport(w http.ResponseWriter, r *http.Request) {
 	snapshot := s.snapshot()
 	operations, err := snapshot.Operations()
@@ -264,6 +571,8 @@ func (s *Server) snapshot() Snapshot {
 					datacenter.Services = append(datacenter.Services, service)
 				case service.Kind == "external":
 					datacenter.ExternalServices = append(datacenter.ExternalServices, service)
+				case service.Kind == "native":
+					datacenter.NativeServices = append(datacenter.NativeServices, service)
 				case service.Kind == "mesh":
 					// special case the mesh gateways since they need to be booted up early
 					datacenter.MeshGateways = append(datacenter.MeshGateways, service)
@@ -11,15 +11,34 @@ type Service struct {
 	NamedPorts map[string]int
 	Ports      []int
 	Logs       string
+	// the zone the instance is deployed in, if any
+	Zone string `json:",omitempty"`
+	// the instance group the instance belongs to, if any
+	Group string `json:",omitempty"`
+	// for proxies and connect-native services, the protocols of each upstream keyed by name
+	Upstreams map[string]string `json:",omitempty"`
+	// for connect-native services, the name the service is registered with in Consul
+	ConsulService string `json:",omitempty"`
+	// for proxies, the sidecar running them, one of envoy, builtin or dataplane
+	Sidecar string `json:",omitempty"`
 	// the below values are all with regard to the registration
 	// information of the service
 	ServiceDefaultsFile     string `json:"-"`
 	ServiceRegistrationFile string `json:"-"`
 	ServiceProxyFile        string `json:"-"`
 	ConsulAddress           string `json:"-"`
+	// for dataplane sidecars
+	ConsulGRPCPort int    `json:"-"`
+	NodeName       string `json:"-"`
+	GracefulPort   int    `json:"-"`
 	// for gateways
-	RegisteredPort int `json:"-"`
+	Listeners      []Listener `json:",omitempty"`
+	RegisteredPort int        `json:"-"`
+	ExposeServers  bool       `json:"-"`
 	// for services
-	Protocol    string `json:"-"`
-	ServicePort int    `json:"-"`
+	Protocol    string      `json:"-"`
+	ServicePort int         `json:"-"`
+	Requests    *RequestLog `json:"-"`
+	Chaos       *ChaosStore `json:"-"`
+	Lifecycle   Lifecycle   `json:"-"`
 }
@@ -17,6 +17,7 @@ type DatacenterSnapshot struct {
 	Consul           *Consul
 	ExternalServices []Service
 	ServiceProxies   []Service
+	NativeServices   []Service
 	Services         []Service
 	MeshGateways     []Service
 	Gateways         []Service
@@ -126,14 +127,19 @@ type RunGateway struct {
 }
 
 func (g *RunGateway) Script() string {
-	return fmt.Sprintf(`echo "Running '%s' gateway '%s'"
-%s`, g.service.Kind, g.service.Name, background(commands.ConsulCommand(commands.GatewayRegistrationArgs(
+	args := commands.GatewayRegistrationArgs(
 		strings.TrimSuffix(g.service.Kind, "-gateway"),
 		g.service.Name,
 		g.service.ConsulAddress,
 		g.service.AdminPort,
 		g.service.RegisteredPort,
-	))))
+	)
+	if g.service.ExposeServers {
+		args = commands.WithExposedServers(args)
+	}
+
+	return fmt.Sprintf(`echo "Running '%s' gateway '%s'"
+%s`, g.service.Kind, g.service.Name, background(commands.ConsulCommand(args)))
 }
 
 type RunService struct {
@@ -161,22 +167,71 @@ ncat -e "/bin/cat %s" -k -l %d &
 			tmpFilename(fmt.Sprintf("%s.html", s.service.Name)),
 			s.service.ServicePort,
 		)
+	case "http2", "grpc":
+		// there's no common tool that speaks these, so use our own test service
+		return fmt.Sprintf(`echo "Running '%s' service '%s'"
+%s`, s.service.Protocol, s.service.Name, background(fmt.Sprintf(
+			"consul-services serve --protocol %s --port %d --id %s --datacenter %s",
+			s.service.Protocol,
+			s.service.ServicePort,
+			s.service.Name,
+			s.service.Datacenter,
+		)))
 	default:
 		return fmt.Sprintf("# unsupported service type %s for service %q", s.service.Protocol, s.service.Name)
 	}
 }
 
+// RunNativeService runs a connect-native service, which only our own test
+// services know how to do
+type RunNativeService struct {
+	service Service
+}
+
+func (s *RunNativeService) Script() string {
+	return fmt.Sprintf(`echo "Running connect-native service '%s'"
+%s`, s.service.Name, background(fmt.Sprintf(
+		"consul-services serve --protocol %s --port %d --id %s --datacenter %s --native %s --consul-address %s",
+		s.service.Protocol,
+		s.service.ServicePort,
+		s.service.Name,
+		s.service.Datacenter,
+		s.service.ConsulService,
+		s.service.ConsulAddress,
+	)))
+}
+
 type RunSidecar struct {
 	service Service
 }
 
 func (s *RunSidecar) Script() string {
+	var command string
+	switch s.service.Sidecar {
+	case "builtin":
+		command = commands.ConsulCommand(commands.BuiltinSidecarArgs(
+			s.service.ConsulAddress,
+			s.service.Name,
+		))
+	case "dataplane":
+		command = commands.DataplaneCommand(commands.DataplaneArgs(
+			"127.0.0.1",
+			s.service.ConsulGRPCPort,
+			s.service.NodeName,
+			s.service.Name+"-proxy",
+			s.service.AdminPort,
+			s.service.GracefulPort,
+		))
+	default:
+		command = commands.ConsulCommand(commands.SidecarArgs(
+			s.service.ConsulAddress,
+			s.service.Name,
+			s.service.AdminPort,
+		))
+	}
+
 	return fmt.Sprintf(`echo "Running sidecar for '%s'"
-%s`, s.service.Name, background(commands.ConsulCommand(commands.SidecarArgs(
-		s.service.ConsulAddress,
-		s.service.Name,
-		s.service.AdminPort,
-	))))
+%s`, s.service.Name, background(command))
 }
 
 // OrderedOperation creates an operation that can be transformed into a part of a script.
@@ -219,7 +274,8 @@ func (s *Snapshot) Operations() ([]OrderedOperation, error) {
 		for i := len(s.Datacenters) - 1; i >= 0; i-- {
 			dc := s.Datacenters[i]
 
-			if dc.Consul != nil {
+			// agents federating through mesh gateways find each other through them
+			if dc.Consul != nil && !dc.Consul.Federated {
 				filtered := []string{}
 				for _, wan := range wans {
 					if wan == dc.Consul.WanAddress {
@@ -293,6 +349,22 @@ func (s *Snapshot) Operations() ([]OrderedOperation, error) {
 			}
 		}
 
+		if len(dc.NativeServices) > 0 {
+			operations = append(operations, Block(fmt.Sprintf("Adding %d Connect-Native Service(s) for %q", len(dc.NativeServices), dc.Datacenter)))
+
+			// connect-native services have no proxies
+			defaults, services, _, err := fileSetsForServices(s.logger, dc.NativeServices, false)
+			if err != nil {
+				return nil, err
+			}
+			for _, op := range defaults {
+				operations = append(operations, op)
+			}
+			for _, op := range services {
+				operations = append(operations, op)
+			}
+		}
+
 		if len(dc.ConfigEntries) > 0 {
 			operations = append(operations, Block(fmt.Sprintf("Adding %d Additional Configuration for %q", len(dc.ConfigEntries), dc.Datacenter)))
 
@@ -346,6 +418,16 @@ func (s *Snapshot) Operations() ([]OrderedOperation, error) {
 			}
 		}
 
+		if len(dc.NativeServices) > 0 {
+			operations = append(operations, Block(fmt.Sprintf("Starting %d Connect-Native Service(s) for %q", len(dc.NativeServices), dc.Datacenter)))
+
+			for _, service := range dc.NativeServices {
+				operations = append(operations, &RunNativeService{
+					service: service,
+				})
+			}
+		}
+
 		if len(dc.Gateways) > 0 {
 			operations = append(operations, Block(fmt.Sprintf("Starting %d Gateway(s) for %q", len(dc.Gateways), dc.Datacenter)))
 
@@ -391,7 +473,7 @@ func fileTupleForService(logger hclog.Logger, service Service, isExternal bool)
 		return
 	}
 
-	if !isExternal {
+	if !isExternal && service.ServiceProxyFile != "" {
 		proxyBytes, err = vfs.ReadFile(service.ServiceProxyFile)
 		if err != nil {
 			return
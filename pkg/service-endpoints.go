@@ -0,0 +1,297 @@
+package pkg
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// websocketPath echoes back every message sent over a WebSocket
+	websocketPath = "/ws"
+	// streamPath streams chunks with a delay between each
+	streamPath = "/stream"
+	// ssePath sends server-sent events with a delay between each
+	ssePath = "/sse"
+	// pollPath holds the request open before responding
+	pollPath = "/poll"
+	// bytesPath responds with a body of a given size
+	bytesPath = "/bytes"
+	// uploadPath echoes back the request body
+	uploadPath = "/upload"
+
+	defaultStreamCount    = 10
+	defaultStreamInterval = 100 * time.Millisecond
+	defaultSSEInterval    = time.Second
+	defaultPollWait       = 30 * time.Second
+	defaultBytesSize      = 1024 * 1024
+	// maxBodySize bounds the bodies sent by the bytes endpoint, which writes in chunks
+	maxBodySize = 1024 * 1024 * 1024
+	// maxUploadSize bounds the bodies echoed back by the upload endpoint, which holds
+	// the whole body in memory
+	maxUploadSize = 64 * 1024 * 1024
+)
+
+// serveEndpoint serves the streaming and sizing endpoints of HTTP services, returning
+// false if the request wasn't for one of them
+func (s *Service) serveEndpoint(w http.ResponseWriter, r *http.Request) bool {
+	var err error
+
+	switch r.URL.Path {
+	case websocketPath:
+		err = s.serveWebsocket(w, r)
+	case streamPath:
+		err = s.serveStream(w, r)
+	case ssePath:
+		err = s.serveSSE(w, r)
+	case pollPath:
+		err = s.servePoll(w, r)
+	case bytesPath:
+		err = s.serveBytes(w, r)
+	case uploadPath:
+		err = s.serveUpload(w, r)
+	default:
+		return false
+	}
+
+	var queryErr *queryError
+	if errors.As(err, &queryErr) {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, queryErr.Error())
+		return true
+	}
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		w.WriteHeader(http.StatusRequestEntityTooLarge)
+		fmt.Fprintf(w, "body larger than %d bytes", maxBytesErr.Limit)
+		return true
+	}
+	if err != nil {
+		s.Logger.Warn("error serving endpoint", "path", r.URL.Path, "err", err)
+	}
+	return true
+}
+
+var upgrader = websocket.Upgrader{
+	// anything on the mesh is allowed to connect
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+func (s *Service) serveWebsocket(w http.ResponseWriter, r *http.Request) error {
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// the upgrader has already responded
+		return nil
+	}
+	defer conn.Close()
+
+	s.Logger.Info("websocket opened", "source", r.RemoteAddr)
+	defer s.Logger.Info("websocket closed", "source", r.RemoteAddr)
+
+	for {
+		messageType, message, err := conn.ReadMessage()
+		if err != nil {
+			if websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				return nil
+			}
+			return err
+		}
+		if err := conn.WriteMessage(messageType, message); err != nil {
+			return err
+		}
+	}
+}
+
+// serveStream writes ?count chunks every ?interval using chunked encoding
+func (s *Service) serveStream(w http.ResponseWriter, r *http.Request) error {
+	count, err := queryInt(r, "count", defaultStreamCount)
+	if err != nil {
+		return err
+	}
+	interval, err := queryDuration(r, "interval", defaultStreamInterval)
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("content-type", "text/plain")
+	return s.every(w, r, count, interval, func(i int) {
+		fmt.Fprintf(w, "%s chunk %d\n", s.ID, i)
+	})
+}
+
+// serveSSE sends ?count events every ?interval, a count of 0 streams until the client disconnects
+func (s *Service) serveSSE(w http.ResponseWriter, r *http.Request) error {
+	count, err := queryInt(r, "count", 0)
+	if err != nil {
+		return err
+	}
+	interval, err := queryDuration(r, "interval", defaultSSEInterval)
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("content-type", "text/event-stream")
+	w.Header().Set("cache-control", "no-cache")
+	return s.every(w, r, count, interval, func(i int) {
+		fmt.Fprintf(w, "id: %d\nevent: message\ndata: %s\n\n", i, s.ID)
+	})
+}
+
+// every calls write up to count times, flushing after each and waiting the interval
+// in between, a count of 0 writes until the request is done
+func (s *Service) every(w http.ResponseWriter, r *http.Request, count int, interval time.Duration, write func(i int)) error {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		return errors.New("streaming is unsupported")
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for i := 1; count == 0 || i <= count; i++ {
+		write(i)
+		flusher.Flush()
+
+		if i == count {
+			break
+		}
+
+		select {
+		case <-r.Context().Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+	return nil
+}
+
+// servePoll waits ?wait before responding, as a long-poll would
+func (s *Service) servePoll(w http.ResponseWriter, r *http.Request) error {
+	wait, err := queryDuration(r, "wait", defaultPollWait)
+	if err != nil {
+		return err
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	select {
+	case <-r.Context().Done():
+		return nil
+	case <-timer.C:
+		fmt.Fprint(w, s.ID)
+		return nil
+	}
+}
+
+// serveBytes responds with a body of ?size bytes, i.e. 512, 64KB or 10MB
+func (s *Service) serveBytes(w http.ResponseWriter, r *http.Request) error {
+	size, err := querySize(r, "size", defaultBytesSize)
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("content-type", "application/octet-stream")
+	w.Header().Set("content-length", strconv.FormatInt(size, 10))
+
+	chunk := []byte(strings.Repeat("x", 32*1024))
+	for size > 0 {
+		n := int64(len(chunk))
+		if size < n {
+			n = size
+		}
+		if _, err := w.Write(chunk[:n]); err != nil {
+			return err
+		}
+		size -= n
+	}
+	return nil
+}
+
+// serveUpload echoes back the body of the request
+func (s *Service) serveUpload(w http.ResponseWriter, r *http.Request) error {
+	// read everything before responding since HTTP/1 servers may stop
+	// reading the request once the response has started
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxUploadSize))
+	if err != nil {
+		return err
+	}
+
+	if contentType := r.Header.Get("content-type"); contentType != "" {
+		w.Header().Set("content-type", contentType)
+	}
+	w.Header().Set("content-length", strconv.Itoa(len(body)))
+	_, err = w.Write(body)
+	return err
+}
+
+// queryError is returned when a query parameter can't be parsed
+type queryError struct {
+	name  string
+	value string
+}
+
+func (e *queryError) Error() string {
+	return fmt.Sprintf("invalid %s: %q", e.name, e.value)
+}
+
+func queryInt(r *http.Request, name string, fallback int) (int, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return fallback, nil
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil || parsed < 0 {
+		return 0, &queryError{name: name, value: value}
+	}
+	return parsed, nil
+}
+
+func queryDuration(r *http.Request, name string, fallback time.Duration) (time.Duration, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return fallback, nil
+	}
+	parsed, err := time.ParseDuration(value)
+	if err != nil || parsed <= 0 {
+		return 0, &queryError{name: name, value: value}
+	}
+	return parsed, nil
+}
+
+var sizeUnits = []struct {
+	suffix     string
+	multiplier int64
+}{
+	{"GB", 1024 * 1024 * 1024},
+	{"MB", 1024 * 1024},
+	{"KB", 1024},
+	{"B", 1},
+}
+
+func querySize(r *http.Request, name string, fallback int64) (int64, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return fallback, nil
+	}
+
+	number, multiplier := strings.ToUpper(value), int64(1)
+	for _, unit := range sizeUnits {
+		if strings.HasSuffix(number, unit.suffix) {
+			number, multiplier = strings.TrimSuffix(number, unit.suffix), unit.multiplier
+			break
+		}
+	}
+
+	parsed, err := strconv.ParseInt(number, 10, 64)
+	if err != nil || parsed < 0 || parsed > maxBodySize/multiplier {
+		return 0, &queryError{name: name, value: value}
+	}
+	return parsed * multiplier, nil
+}
@@ -1,24 +1,76 @@
 package pkg
 
 import (
+	"bufio"
 	"context"
+	"crypto/tls"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"net"
 	"net/http"
+	"time"
+
+	"github.com/andrewstucki/consul-services/pkg/echo"
+	"github.com/andrewstucki/consul-services/pkg/server"
+	"github.com/hashicorp/go-hclog"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/protobuf/proto"
 )
 
+// defaultRequestLogSize is the number of recent requests each service keeps around
+const defaultRequestLogSize = 100
+
 // Service is a service that runs on the service mesh
 type Service struct {
-	ID       string
-	Protocol string
-	Port     int
+	ID         string
+	Datacenter string
+	Protocol   string
+	Port       int
+	Logger     hclog.Logger
+	// Requests records the traffic received by the service
+	Requests *server.RequestLog
+	// Group is the instance group the service belongs to, if any
+	Group *InstanceGroup
+	// TLS, if set, serves the service over TLS, used by connect-native services
+	TLS *tls.Config
+	// Calls are the upstreams the service calls when it receives a request,
+	// responding with the tree of their responses
+	Calls []ServiceCall
+
+	// gate lets the service be killed or paused
+	gate *instanceGate
 }
 
 func (s *Service) Run(ctx context.Context) error {
+	if s.Logger == nil {
+		s.Logger = hclog.NewNullLogger()
+	}
+	if s.Requests == nil {
+		s.Requests = server.NewRequestLog(defaultRequestLogSize)
+	}
+	if s.gate == nil {
+		s.gate = newInstanceGate()
+	}
+
+	s.Logger.Info("starting service", "id", s.ID, "protocol", s.Protocol, "port", s.Port)
+	defer s.Logger.Info("stopping service", "id", s.ID)
+
 	switch s.Protocol {
 	case protocolHTTP:
-		return s.runHTTPService(ctx)
+		return s.runHTTPService(ctx, false)
+	case protocolHTTP2:
+		return s.runHTTPService(ctx, true)
+	case protocolGRPC:
+		return s.runGRPCService(ctx)
 	case protocolTCP:
 		return s.runTCPService(ctx)
 	default:
@@ -27,11 +79,12 @@ func (s *Service) Run(ctx context.Context) error {
 }
 
 func (s *Service) runTCPService(ctx context.Context) error {
-	listener, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", s.Port))
+	listener, err := s.listen()
 	if err != nil {
 		return err
 	}
 	defer listener.Close()
+	listener = s.gate.listen(listener)
 
 	go func() {
 		for {
@@ -39,8 +92,15 @@ func (s *Service) runTCPService(ctx context.Context) error {
 			if err != nil {
 				return
 			}
-			fmt.Fprintf(conn, s.ID)
+			s.Logger.Info("connection received", "source", conn.RemoteAddr().String())
+			written, _ := fmt.Fprint(conn, s.ID)
 			conn.Close()
+
+			s.Requests.Record(server.Request{
+				Time:      time.Now(),
+				Source:    conn.RemoteAddr().String(),
+				BytesSent: int64(written),
+			})
 		}
 	}()
 
@@ -49,21 +109,200 @@ func (s *Service) runTCPService(ctx context.Context) error {
 	return nil
 }
 
-func (s *Service) runHTTPService(ctx context.Context) error {
-	server := &http.Server{
-		Addr: fmt.Sprintf("127.0.0.1:%d", s.Port),
+func (s *Service) runHTTPService(ctx context.Context, cleartextHTTP2 bool) error {
+	listener, err := s.listen()
+	if err != nil {
+		return err
+	}
+
+	handler := s.gate.handler(s.recordRequests(s.httpHandler()))
+	if cleartextHTTP2 {
+		// envoy speaks HTTP/2 to the service with prior knowledge, so serve it
+		// in cleartext, this needs to wrap everything since it hijacks connections
+		handler = h2c.NewHandler(handler, &http2.Server{})
+	}
+
+	httpServer := &http.Server{
 		BaseContext: func(l net.Listener) context.Context {
 			return ctx
 		},
-		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			fmt.Fprintf(w, s.ID)
-		}),
+		Handler: handler,
+	}
+	defer httpServer.Close()
+
+	go httpServer.Serve(s.gate.listen(listener))
+
+	<-ctx.Done()
+
+	return nil
+}
+
+func (s *Service) httpHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.serveEndpoint(w, r) {
+			return
+		}
+		if len(s.Calls) > 0 {
+			s.serveCalls(w, r)
+			return
+		}
+		if echo.Requested(r) {
+			w.Header().Set("content-type", "application/json")
+			json.NewEncoder(w).Encode(s.withGroup(echo.NewResponse(s.ID, s.Datacenter, r)))
+			return
+		}
+		if s.Group != nil && s.Group.Body != "" {
+			fmt.Fprint(w, s.Group.Body)
+			return
+		}
+		fmt.Fprint(w, s.ID)
+	})
+}
+
+func (s *Service) runGRPCService(ctx context.Context) error {
+	listener, err := s.listen()
+	if err != nil {
+		return err
 	}
-	defer server.Close()
 
-	go server.ListenAndServe()
+	grpcServer := grpc.NewServer(grpc.ChainUnaryInterceptor(s.gate.unaryInterceptor, s.recordGRPCRequests))
+	defer grpcServer.Stop()
+
+	echo.RegisterGRPC(grpcServer, func(ctx context.Context) *echo.Response {
+		return s.withGroup(echo.NewGRPCResponse(s.ID, s.Datacenter, ctx))
+	})
+	healthpb.RegisterHealthServer(grpcServer, health.NewServer())
+	reflection.Register(grpcServer)
+
+	go grpcServer.Serve(s.gate.listen(listener))
 
 	<-ctx.Done()
 
 	return nil
 }
+
+// listen listens on the port of the service, over TLS if configured
+func (s *Service) listen() (net.Listener, error) {
+	listener, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", s.Port))
+	if err != nil {
+		return nil, err
+	}
+	if s.TLS != nil {
+		return tls.NewListener(listener, s.TLS), nil
+	}
+	return listener, nil
+}
+
+// withGroup adds the details of the instance group to an echo response
+func (s *Service) withGroup(response *echo.Response) *echo.Response {
+	if s.Group != nil {
+		response.Group = s.Group.Name
+		response.Tags = s.Group.Tags
+		response.Meta = s.Group.Meta
+	}
+	return response
+}
+
+// recordRequests wraps an HTTP handler, logging and recording every request it serves
+func (s *Service) recordRequests(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.Logger.Info("request received", "method", r.Method, "path", r.URL.Path, "host", r.Host, "source", r.RemoteAddr)
+
+		body := &countingReader{reader: r.Body}
+		r.Body = body
+		writer := &countingResponseWriter{ResponseWriter: w}
+
+		handler.ServeHTTP(writer, r)
+
+		// drain anything the handler didn't read so we have an accurate count
+		io.Copy(io.Discard, body)
+
+		s.Requests.Record(server.Request{
+			Time:          time.Now(),
+			Method:        r.Method,
+			Path:          r.URL.RequestURI(),
+			Host:          r.Host,
+			Headers:       r.Header.Clone(),
+			Source:        r.RemoteAddr,
+			BytesReceived: body.count,
+			BytesSent:     writer.count,
+		})
+	})
+}
+
+// recordGRPCRequests is a gRPC interceptor, logging and recording every unary call the service handles
+func (s *Service) recordGRPCRequests(ctx context.Context, request interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	host := ""
+	if authority := md.Get(":authority"); len(authority) > 0 {
+		host = authority[0]
+	}
+	source := ""
+	if p, ok := peer.FromContext(ctx); ok {
+		source = p.Addr.String()
+	}
+
+	s.Logger.Info("request received", "method", info.FullMethod, "host", host, "source", source)
+
+	response, err := handler(ctx, request)
+
+	record := server.Request{
+		Time:    time.Now(),
+		Method:  http.MethodPost,
+		Path:    info.FullMethod,
+		Host:    host,
+		Headers: http.Header(md.Copy()),
+		Source:  source,
+	}
+	if message, ok := request.(proto.Message); ok {
+		record.BytesReceived = int64(proto.Size(message))
+	}
+	if message, ok := response.(proto.Message); ok && err == nil {
+		record.BytesSent = int64(proto.Size(message))
+	}
+	s.Requests.Record(record)
+
+	return response, err
+}
+
+type countingReader struct {
+	reader io.ReadCloser
+	count  int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.reader.Read(p)
+	c.count += int64(n)
+	return n, err
+}
+
+func (c *countingReader) Close() error {
+	return c.reader.Close()
+}
+
+type countingResponseWriter struct {
+	http.ResponseWriter
+	count int64
+}
+
+func (c *countingResponseWriter) Write(p []byte) (int, error) {
+	n, err := c.ResponseWriter.Write(p)
+	c.count += int64(n)
+	return n, err
+}
+
+// Flush implements http.Flusher so that streaming endpoints work through the recorder
+func (c *countingResponseWriter) Flush() {
+	if flusher, ok := c.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Hijack implements http.Hijacker so that WebSockets can be upgraded through the recorder
+func (c *countingResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := c.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("hijacking is unsupported")
+	}
+	return hijacker.Hijack()
+}
@@ -0,0 +1,24 @@
+//go:build !windows
+
+package pkg
+
+import (
+	"os"
+	"syscall"
+
+	"github.com/andrewstucki/consul-services/pkg/server"
+)
+
+// processSignal returns the signal used to perform the given action on a process
+func processSignal(action string) (os.Signal, error) {
+	switch action {
+	case server.ActionKill:
+		return syscall.SIGKILL, nil
+	case server.ActionPause:
+		return syscall.SIGSTOP, nil
+	case server.ActionResume:
+		return syscall.SIGCONT, nil
+	default:
+		return nil, server.ValidateAction(action)
+	}
+}
@@ -0,0 +1,22 @@
+//go:build windows
+
+package pkg
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/andrewstucki/consul-services/pkg/server"
+)
+
+// processSignal returns the signal used to perform the given action on a process
+func processSignal(action string) (os.Signal, error) {
+	switch action {
+	case server.ActionKill:
+		return os.Kill, nil
+	case server.ActionPause, server.ActionResume:
+		return nil, fmt.Errorf("%s is not supported on windows", action)
+	default:
+		return nil, server.ValidateAction(action)
+	}
+}
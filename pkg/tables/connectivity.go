@@ -0,0 +1,59 @@
+package tables
+
+import (
+	"io"
+	"time"
+
+	"github.com/olekukonko/tablewriter"
+)
+
+// ConnectivityResult is the result of probing a source's upstream
+type ConnectivityResult struct {
+	Source   string
+	Upstream string
+	Result   string
+	Latency  time.Duration
+	Detail   string
+}
+
+// PrintConnectivity pretty prints the results of connectivity probes in a table
+func PrintConnectivity(w io.Writer, results []ConnectivityResult) {
+	var resultTable [][]string
+	for _, result := range results {
+		resultTable = append(resultTable, formatConnectivity(result))
+	}
+
+	table := tablewriter.NewWriter(w)
+	table.SetHeader([]string{"Source", "Upstream", "Result", "Latency", "Detail"})
+	table.SetHeaderColor(
+		tablewriter.Colors{tablewriter.Bold, tablewriter.FgHiBlueColor},
+		tablewriter.Colors{tablewriter.Bold, tablewriter.FgHiBlueColor},
+		tablewriter.Colors{tablewriter.Bold, tablewriter.FgHiBlueColor},
+		tablewriter.Colors{tablewriter.Bold, tablewriter.FgHiBlueColor},
+		tablewriter.Colors{tablewriter.Bold, tablewriter.FgHiBlueColor},
+	)
+	table.SetColumnColor(
+		tablewriter.Colors{tablewriter.Bold, tablewriter.FgHiGreenColor},
+		tablewriter.Colors{},
+		tablewriter.Colors{},
+		tablewriter.Colors{},
+		tablewriter.Colors{},
+	)
+	table.SetAutoMergeCellsByColumnIndex([]int{0})
+	table.SetAutoWrapText(false)
+	table.SetRowLine(false)
+	table.SetBorder(false)
+	table.AppendBulk(resultTable)
+
+	table.Render()
+}
+
+func formatConnectivity(result ConnectivityResult) []string {
+	return []string{
+		result.Source,
+		result.Upstream,
+		result.Result,
+		result.Latency.Round(time.Microsecond).String(),
+		result.Detail,
+	}
+}
@@ -0,0 +1,82 @@
+package tables
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/olekukonko/tablewriter"
+)
+
+const (
+	barWidth = 40
+)
+
+// Bucket is a single labeled count in a distribution
+type Bucket struct {
+	Label string
+	Count int
+}
+
+// SortedBuckets converts a map of counts into buckets ordered by label
+func SortedBuckets(counts map[string]int) []Bucket {
+	buckets := []Bucket{}
+	for label, count := range counts {
+		buckets = append(buckets, Bucket{Label: label, Count: count})
+	}
+	sort.SliceStable(buckets, func(i, j int) bool {
+		return buckets[i].Label < buckets[j].Label
+	})
+	return buckets
+}
+
+// PrintDistribution pretty prints counts along with their share of the
+// total and a bar representing it
+func PrintDistribution(w io.Writer, header string, buckets []Bucket) {
+	total := 0
+	for _, bucket := range buckets {
+		total += bucket.Count
+	}
+
+	var distributionTable [][]string
+	for _, bucket := range buckets {
+		distributionTable = append(distributionTable, formatBucket(bucket, total))
+	}
+
+	table := tablewriter.NewWriter(w)
+	table.SetHeader([]string{header, "Count", "Percent", ""})
+	table.SetHeaderColor(
+		tablewriter.Colors{tablewriter.Bold, tablewriter.FgHiBlueColor},
+		tablewriter.Colors{tablewriter.Bold, tablewriter.FgHiBlueColor},
+		tablewriter.Colors{tablewriter.Bold, tablewriter.FgHiBlueColor},
+		tablewriter.Colors{tablewriter.Bold, tablewriter.FgHiBlueColor},
+	)
+	table.SetColumnColor(
+		tablewriter.Colors{tablewriter.Bold, tablewriter.FgHiGreenColor},
+		tablewriter.Colors{},
+		tablewriter.Colors{},
+		tablewriter.Colors{tablewriter.FgHiCyanColor},
+	)
+	table.SetAutoWrapText(false)
+	table.SetRowLine(false)
+	table.SetBorder(false)
+	table.AppendBulk(distributionTable)
+
+	table.Render()
+}
+
+func formatBucket(bucket Bucket, total int) []string {
+	share := 0.0
+	if total > 0 {
+		share = float64(bucket.Count) / float64(total)
+	}
+
+	return []string{
+		bucket.Label,
+		strconv.Itoa(bucket.Count),
+		fmt.Sprintf("%.1f%%", share*100),
+		strings.Repeat("█", int(share*barWidth)),
+	}
+}
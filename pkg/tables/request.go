@@ -0,0 +1,61 @@
+package tables
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/andrewstucki/consul-services/pkg/echo"
+	"github.com/andrewstucki/consul-services/pkg/server"
+	"github.com/olekukonko/tablewriter"
+)
+
+// PrintRequests pretty prints requests received by a service in a table
+func PrintRequests(w io.Writer, requests []server.Request) {
+	var requestTable [][]string
+	for _, request := range requests {
+		requestTable = append(requestTable, formatRequest(request))
+	}
+
+	table := tablewriter.NewWriter(w)
+	table.SetHeader([]string{"Time", "Source", "Method", "Host", "Path", "Received", "Sent", "Client"})
+	table.SetHeaderColor(
+		tablewriter.Colors{tablewriter.Bold, tablewriter.FgHiBlueColor},
+		tablewriter.Colors{tablewriter.Bold, tablewriter.FgHiBlueColor},
+		tablewriter.Colors{tablewriter.Bold, tablewriter.FgHiBlueColor},
+		tablewriter.Colors{tablewriter.Bold, tablewriter.FgHiBlueColor},
+		tablewriter.Colors{tablewriter.Bold, tablewriter.FgHiBlueColor},
+		tablewriter.Colors{tablewriter.Bold, tablewriter.FgHiBlueColor},
+		tablewriter.Colors{tablewriter.Bold, tablewriter.FgHiBlueColor},
+		tablewriter.Colors{tablewriter.Bold, tablewriter.FgHiBlueColor},
+	)
+	table.SetColumnColor(
+		tablewriter.Colors{tablewriter.Bold, tablewriter.FgHiGreenColor},
+		tablewriter.Colors{},
+		tablewriter.Colors{},
+		tablewriter.Colors{},
+		tablewriter.Colors{},
+		tablewriter.Colors{},
+		tablewriter.Colors{},
+		tablewriter.Colors{},
+	)
+	table.SetAutoWrapText(false)
+	table.SetRowLine(false)
+	table.SetBorder(false)
+	table.AppendBulk(requestTable)
+
+	table.Render()
+}
+
+func formatRequest(request server.Request) []string {
+	return []string{
+		request.Time.Format("15:04:05.000"),
+		request.Source,
+		request.Method,
+		request.Host,
+		request.Path,
+		strconv.FormatInt(request.BytesReceived, 10),
+		strconv.FormatInt(request.BytesSent, 10),
+		echo.ClientURI(http.Header(request.Headers).Get(echo.ClientCertificateHeader)),
+	}
+}
@@ -9,27 +9,53 @@ import (
 	"github.com/olekukonko/tablewriter"
 )
 
-// PrintServices pretty prints services in a table
+// PrintServices pretty prints services in a table, the zone and group of
+// each service are only shown if any service was deployed with one, and the
+// sidecar of each proxy only if any proxy isn't envoy
 func PrintServices(w io.Writer, services []server.Service) {
+	showZones, showGroups, showSidecars := false, false, false
+	for _, service := range services {
+		if service.Zone != "" {
+			showZones = true
+		}
+		if service.Group != "" {
+			showGroups = true
+		}
+		if service.Sidecar != "" && service.Sidecar != "envoy" {
+			showSidecars = true
+		}
+	}
+
 	var serviceTable [][]string
 	for _, service := range services {
-		serviceTable = append(serviceTable, formatService(service))
+		serviceTable = append(serviceTable, formatService(service, showZones, showGroups, showSidecars))
+	}
+
+	header := []string{"Kind", "Name"}
+	if showZones {
+		header = append(header, "Zone")
+	}
+	if showGroups {
+		header = append(header, "Group")
+	}
+	if showSidecars {
+		header = append(header, "Sidecar")
+	}
+	header = append(header, "Admin Port", "Ports")
+
+	headerColors := []tablewriter.Colors{}
+	columnColors := []tablewriter.Colors{{tablewriter.Bold, tablewriter.FgHiGreenColor}}
+	for i := range header {
+		headerColors = append(headerColors, tablewriter.Colors{tablewriter.Bold, tablewriter.FgHiBlueColor})
+		if i > 0 {
+			columnColors = append(columnColors, tablewriter.Colors{})
+		}
 	}
 
 	table := tablewriter.NewWriter(w)
-	table.SetHeader([]string{"Kind", "Name", "Admin Port", "Ports"})
-	table.SetHeaderColor(
-		tablewriter.Colors{tablewriter.Bold, tablewriter.FgHiBlueColor},
-		tablewriter.Colors{tablewriter.Bold, tablewriter.FgHiBlueColor},
-		tablewriter.Colors{tablewriter.Bold, tablewriter.FgHiBlueColor},
-		tablewriter.Colors{tablewriter.Bold, tablewriter.FgHiBlueColor},
-	)
-	table.SetColumnColor(
-		tablewriter.Colors{tablewriter.Bold, tablewriter.FgHiGreenColor},
-		tablewriter.Colors{},
-		tablewriter.Colors{},
-		tablewriter.Colors{},
-	)
+	table.SetHeader(header)
+	table.SetHeaderColor(headerColors...)
+	table.SetColumnColor(columnColors...)
 	table.SetAutoMergeCells(true)
 	table.SetRowLine(false)
 	table.SetBorder(false)
@@ -38,7 +64,7 @@ func PrintServices(w io.Writer, services []server.Service) {
 	table.Render()
 }
 
-func formatService(service server.Service) []string {
+func formatService(service server.Service, showZone, showGroup, showSidecar bool) []string {
 	ports := []string{}
 	for _, port := range service.Ports {
 		ports = append(ports, strconv.Itoa(port))
@@ -49,5 +75,15 @@ func formatService(service server.Service) []string {
 		adminPort = strconv.Itoa(service.AdminPort)
 	}
 
-	return []string{service.Kind, service.Name, adminPort, strings.Join(ports, ", ")}
+	row := []string{service.Kind, service.Name}
+	if showZone {
+		row = append(row, service.Zone)
+	}
+	if showGroup {
+		row = append(row, service.Group)
+	}
+	if showSidecar {
+		row = append(row, service.Sidecar)
+	}
+	return append(row, adminPort, strings.Join(ports, ", "))
 }
@@ -12,14 +12,18 @@ const (
 )
 
 const (
-	protocolHTTP = "http"
-	protocolTCP  = "tcp"
+	protocolHTTP  = "http"
+	protocolHTTP2 = "http2"
+	protocolTCP   = "tcp"
+	protocolGRPC  = "grpc"
 
 	agentTemplate           = "agent.hcl"
 	externalServiceTemplate = "external-service.json"
 	serviceTemplate         = "service.hcl"
 	serviceDefaultsTemplate = "service-defaults.hcl"
 	serviceProxyTemplate    = "service-proxy.hcl"
+
+	terminatingGatewayTemplate = "terminating-gateway.hcl"
 )
 
 type templateArgs struct {
@@ -33,10 +37,36 @@ type templateArgs struct {
 	ProxyPort int
 	// the port that the service is served on
 	ServicePort int
+	// the port that the sidecar forwards traffic to, either the service
+	// port or the port of a chaos proxy in front of the service
+	LocalServicePort int
 	// the protocol to use
 	Protocol string
 	// external upstreams to add
-	ExternalUpstreams []string
+	ExternalUpstreams []upstream
+	// mesh upstreams to add for the services that the service calls
+	MeshUpstreams []upstream
+	// the region and zone the instance is deployed in
+	Locality serviceLocality
+	// the group of the instance, used for its tags and metadata
+	Group *InstanceGroup
+	// whether the service is connect-native rather than using a sidecar
+	Native bool
+}
+
+// serviceLocality is the physical region and zone an instance runs in, used
+// for locality-aware routing
+type serviceLocality struct {
+	Region string
+	Zone   string
+}
+
+// upstream is an upstream service added to a sidecar proxy
+type upstream struct {
+	// Name is the name of the upstream service
+	Name string
+	// Protocol is the protocol the upstream service speaks
+	Protocol string
 }
 
 var (
@@ -1,5 +1,13 @@
 primary_datacenter = "{{ .PrimaryDatacenter }}"
 datacenter = "{{ .Datacenter }}"
+{{- if .Locality.Region }}
+locality = {
+  region = "{{ .Locality.Region }}"
+  {{- if .Locality.Zone }}
+  zone = "{{ .Locality.Zone }}"
+  {{- end }}
+}
+{{- end }}
 addresses = {
   dns = "127.0.0.1"
   http = "127.0.0.1"
@@ -16,4 +24,27 @@ ports = {
   serf_wan = {{ .GetNamedPort "serf_wan" }}
   https = -1
   grpc_tls = -1
-}
\ No newline at end of file
+}
+{{- if .WANFederation }}
+
+connect {
+  enabled                            = true
+  enable_mesh_gateway_wan_federation = true
+}
+{{- if .PrimaryGateway }}
+primary_gateways = ["{{ .PrimaryGateway }}"]
+{{- end }}
+
+tls {
+  defaults {
+    ca_file   = "{{ .TLS.CAFile }}"
+    cert_file = "{{ .TLS.CertFile }}"
+    key_file  = "{{ .TLS.KeyFile }}"
+  }
+  internal_rpc {
+    verify_incoming        = true
+    verify_outgoing        = true
+    verify_server_hostname = true
+  }
+}
+{{- end }}
@@ -0,0 +1,21 @@
+Kind = "api-gateway"
+Name = "{{ .Name }}"
+Listeners = [
+{{- range $listener := .Listeners }}
+  {
+    Name     = "{{ $listener }}"
+    Port     = {{ printf "{{ .GetNamedPort %q }}" $listener }}
+    Protocol = "{{ if eq $listener "tcp" }}tcp{{ else }}http{{ end }}"
+    {{- if eq $listener "https" }}
+    TLS = {
+      Certificates = [
+        {
+          Kind = "inline-certificate"
+          Name = "{{ $.Name }}"
+        }
+      ]
+    }
+    {{- end }}
+  },
+{{- end }}
+]
@@ -0,0 +1,36 @@
+Kind = "http-route"
+Name = "{{ .Name }}"
+{{- if .Hostnames }}
+Hostnames = [{{ range $i, $hostname := .Hostnames }}{{ if $i }}, {{ end }}"{{ $hostname }}"{{ end }}]
+{{- end }}
+Rules = [
+  {
+    {{- if .Path }}
+    Matches = [
+      {
+        Path = {
+          Match = "prefix"
+          Value = "{{ .Path }}"
+        }
+      }
+    ]
+    {{- end }}
+    Services = [
+    {{- range $service := .Services }}
+      {
+        Name = "{{ $service }}"
+      },
+    {{- end }}
+    ]
+  }
+]
+
+Parents = [
+{{- range $listener := .Listeners }}
+  {
+    Kind        = "api-gateway"
+    Name        = "{{ $.Gateway }}"
+    SectionName = "{{ $listener }}"
+  },
+{{- end }}
+]
@@ -0,0 +1,10 @@
+{{ printf "{{ $certificate := .GetCertificate %q" .Name }}{{ range $san := .SANs }} {{ printf "%q" $san }}{{ end }} }}
+
+Kind = "inline-certificate"
+Name = "{{ .Name }}"
+PrivateKey = <<EOF
+{{ "{{ $certificate.PrivateKey }}" }}
+EOF
+Certificate = <<EOF
+{{ "{{ $certificate.Chain }}" }}
+EOF
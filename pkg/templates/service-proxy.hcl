@@ -3,17 +3,44 @@ Service {
   Name = "{{ .Name }}-proxy"
   ID   = "{{ .ID }}-proxy"
   Port = {{ .ProxyPort }}
+{{- with .Group }}
+  {{- if .Tags }}
+  Tags = [{{ range $i, $tag := .Tags }}{{ if $i }}, {{ end }}{{ printf "%q" $tag }}{{ end }}]
+  {{- end }}
+  {{- if .Meta }}
+  Meta = {
+    {{- range $key, $value := .Meta }}
+    {{ printf "%q" $key }} = {{ printf "%q" $value }}
+    {{- end }}
+  }
+  {{- end }}
+{{- end }}
+{{- if .Locality.Region }}
+
+  Locality {
+    Region = "{{ .Locality.Region }}"
+    {{- if .Locality.Zone }}
+    Zone   = "{{ .Locality.Zone }}"
+    {{- end }}
+  }
+{{- end }}
 
   proxy = {
     destination_service_name  = "{{ .Name }}"
     destination_service_id    = "{{ .ID }}"
     local_service_address     = "127.0.0.1"
-    local_service_port        = {{ .ServicePort }}
+    local_service_port        = {{ .LocalServicePort }}
     {{ $service := . }}
     {{- range $upstream := .ExternalUpstreams }}
     upstreams {
-      destination_name = "{{ $upstream }}"
-      local_bind_port = {{ $service.GetNamedPort $upstream }}
+      destination_name = "{{ $upstream.Name }}"
+      local_bind_port = {{ $service.GetNamedPort $upstream.Name }}
+    }
+    {{- end }}
+    {{- range $upstream := .MeshUpstreams }}
+    upstreams {
+      destination_name = "{{ $upstream.Name }}"
+      local_bind_port = {{ $service.GetNamedPort $upstream.Name }}
     }
     {{- end }}
   }
@@ -2,4 +2,31 @@ Service {
   Name = "{{ .Name }}"
   ID   = "{{ .ID }}"
   Port = {{ .ServicePort }}
+{{- with .Group }}
+  {{- if .Tags }}
+  Tags = [{{ range $i, $tag := .Tags }}{{ if $i }}, {{ end }}{{ printf "%q" $tag }}{{ end }}]
+  {{- end }}
+  {{- if .Meta }}
+  Meta = {
+    {{- range $key, $value := .Meta }}
+    {{ printf "%q" $key }} = {{ printf "%q" $value }}
+    {{- end }}
+  }
+  {{- end }}
+{{- end }}
+{{- if .Locality.Region }}
+
+  Locality {
+    Region = "{{ .Locality.Region }}"
+    {{- if .Locality.Zone }}
+    Zone   = "{{ .Locality.Zone }}"
+    {{- end }}
+  }
+{{- end }}
+{{- if .Native }}
+
+  Connect {
+    Native = true
+  }
+{{- end }}
 }
\ No newline at end of file
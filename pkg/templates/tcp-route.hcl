@@ -0,0 +1,19 @@
+Kind = "tcp-route"
+Name = "{{ .Name }}"
+Services = [
+{{- range $service := .Services }}
+  {
+    Name = "{{ $service }}"
+  },
+{{- end }}
+]
+
+Parents = [
+{{- range $listener := .Listeners }}
+  {
+    Kind        = "api-gateway"
+    Name        = "{{ $.Gateway }}"
+    SectionName = "{{ $listener }}"
+  },
+{{- end }}
+]
@@ -0,0 +1,14 @@
+Kind = "terminating-gateway"
+Name = "{{ .Name }}"
+
+Services = [
+{{- range $service := .Services }}
+  {
+    Name = "{{ $service.Name }}"
+    {{- if $service.CAFile }}
+    CAFile = "{{ $service.CAFile }}"
+    SNI    = "{{ $service.SNI }}"
+    {{- end }}
+  },
+{{- end }}
+]
@@ -0,0 +1,69 @@
+package pkg
+
+import (
+	"path"
+
+	"github.com/andrewstucki/consul-services/pkg/server"
+	"github.com/andrewstucki/consul-services/pkg/vfs"
+	"github.com/hashicorp/consul/api"
+)
+
+// terminatingGatewayArgs are used to render the terminating gateway generated
+// for the external services of a datacenter
+type terminatingGatewayArgs struct {
+	// the name of the gateway
+	Name string
+	// the external services linked to the gateway
+	Services []terminatingGatewayService
+}
+
+// terminatingGatewayService is an external service linked to a terminating gateway
+type terminatingGatewayService struct {
+	// the name of the service
+	Name string
+	// the CA used to verify the service, if it serves TLS
+	CAFile string
+	// the server name used when connecting to the service over TLS
+	SNI string
+}
+
+// newTerminatingGateway renders a terminating gateway entry linking the given external services,
+// if useTLS is set the gateway verifies them against the built-in CA.
+func newTerminatingGateway(command *ConsulCommand, server *server.Server, locality locality, services []upstream, useTLS bool) (*ConsulGateway, error) {
+	args := &terminatingGatewayArgs{
+		Name: "terminating-" + locality.Datacenter,
+	}
+
+	caFile := ""
+	if useTLS {
+		ca, err := CertificateAuthority()
+		if err != nil {
+			return nil, err
+		}
+		caFile = path.Join(locality.Datacenter, "terminating-gateway-ca.pem")
+		if err := vfs.WriteFile(caFile, []byte(ca.Certificate), 0600); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, service := range services {
+		linked := terminatingGatewayService{
+			Name: service.Name,
+		}
+		if caFile != "" {
+			linked.CAFile = vfs.PathFor(caFile)
+			linked.SNI = service.Name
+		}
+		args.Services = append(args.Services, linked)
+	}
+
+	entry, err := newGeneratedEntry(command, server, locality, api.TerminatingGateway, args.Name, terminatingGatewayTemplate, args)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ConsulGateway{
+		ConsulConfigEntry: entry,
+		DefinitionFile:    entry.DefinitionFile,
+	}, nil
+}
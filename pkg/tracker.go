@@ -1,33 +1,77 @@
 package pkg
 
-import "fmt"
+import (
+	"fmt"
+	"time"
+)
 
 type tracker struct {
 	ports      []int
 	namedPorts map[string]int
+
+	// unnamedPorts, claimed and unnamedIndex let a template be rendered
+	// multiple times while keeping the ports it was allocated the first time
+	unnamedPorts []int
+	claimed      map[string]struct{}
+	unnamedIndex int
+	renderIndex  int
+	rendered     bool
+
+	// rotation is how often a rendered entry should be re-rendered and re-applied
+	rotation time.Duration
+	// certificates are the certificates issued during the last render
+	certificates []*CertificateInfo
 }
 
 func newTracker() *tracker {
 	return &tracker{
 		namedPorts: make(map[string]int),
+		claimed:    make(map[string]struct{}),
+	}
+}
+
+// beginRender prepares the tracker for rendering a template, on subsequent
+// renders the ports handed out the first time are returned again
+func (t *tracker) beginRender() {
+	if !t.rendered {
+		t.rendered = true
+		t.renderIndex = t.unnamedIndex
+		return
 	}
+
+	t.claimed = make(map[string]struct{})
+	t.unnamedIndex = t.renderIndex
+	t.certificates = nil
 }
 
 func (t *tracker) GetPort() (int, error) {
+	if t.unnamedIndex < len(t.unnamedPorts) {
+		port := t.unnamedPorts[t.unnamedIndex]
+		t.unnamedIndex++
+		return port, nil
+	}
+
 	port, err := freePort()
 	if err != nil {
 		return 0, err
 	}
 
 	t.ports = append(t.ports, port)
+	t.unnamedPorts = append(t.unnamedPorts, port)
+	t.unnamedIndex++
 
 	return port, nil
 }
 
 func (t *tracker) GetNamedPort(name string) (int, error) {
-	if _, ok := t.namedPorts[name]; ok {
+	if _, ok := t.claimed[name]; ok {
 		return 0, fmt.Errorf("name %q already in-use", name)
 	}
+	t.claimed[name] = struct{}{}
+
+	if port, ok := t.namedPorts[name]; ok {
+		return port, nil
+	}
 
 	port, err := freePort()
 	if err != nil {
@@ -46,5 +90,54 @@ func (t *tracker) GetCertificate(name string, sans ...string) (*CertificateInfo,
 		return nil, err
 	}
 
+	t.certificates = append(t.certificates, certificate)
+
 	return certificate, nil
 }
+
+// GetJWKSURL returns the URL of the local JWKS endpoint for jwt-provider entries.
+func (t *tracker) GetJWKSURL() (string, error) {
+	provider, err := JWTProvider()
+	if err != nil {
+		return "", err
+	}
+	return provider.JWKSURL(), nil
+}
+
+// GetJWTIssuer returns the issuer that tokens minted by the local provider use.
+func (t *tracker) GetJWTIssuer() (string, error) {
+	provider, err := JWTProvider()
+	if err != nil {
+		return "", err
+	}
+	return provider.Issuer(), nil
+}
+
+// GetExtAuthzAddress returns the address of the built-in ext_authz service for
+// the given protocol, either "grpc" or "http".
+func (t *tracker) GetExtAuthzAddress(protocol string) (string, error) {
+	return extAuthzAddress(protocol)
+}
+
+// RotateEvery marks the rendered entry to be re-rendered, issuing fresh certificates,
+// and re-applied on the given interval, i.e. "30s".
+func (t *tracker) RotateEvery(interval string) (string, error) {
+	duration, err := time.ParseDuration(interval)
+	if err != nil {
+		return "", err
+	}
+	if duration <= 0 {
+		return "", fmt.Errorf("rotation interval must be positive, got %q", interval)
+	}
+
+	t.rotation = duration
+	return "", nil
+}
+
+func (t *tracker) serialNumbers() []string {
+	serials := []string{}
+	for _, certificate := range t.certificates {
+		serials = append(serials, certificate.SerialNumber)
+	}
+	return serials
+}